@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/git"
+	"github.com/user-cube/gclone/pkg/ui"
+)
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply [path]",
+	Short: "Adopt an existing repository into a profile",
+	Long: `Adopt a repository that was cloned without gclone into a profile.
+The profile is detected from the URL of the 'origin' remote unless --profile is given.
+Every remote is rewritten to use the profile's SSH host and the profile's Git
+configurations are applied to the repository. The changes are shown as a diff
+and applied after confirmation.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := "."
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		repoPath, err := git.FindRepositoryRoot(path)
+		if err != nil {
+			return err
+		}
+
+		configFile, _ := cmd.Flags().GetString("config")
+		cfg, err := config.LoadConfig(configFile)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+
+		profileName, _ := cmd.Flags().GetString("profile")
+		if profileName == "" {
+			profileName, err = detectRepositoryProfile(cfg, repoPath)
+			if err != nil {
+				return err
+			}
+		}
+
+		profile, err := cfg.GetProfile(profileName)
+		if err != nil {
+			return err
+		}
+
+		plan, err := git.PlanApply(repoPath, profileName, &profile)
+		if err != nil {
+			return err
+		}
+
+		ui.OperationInfo("Applying", profileName, map[string]string{"Repository": repoPath})
+		printApplyPlan(cfg, plan)
+
+		if plan.IsEmpty() {
+			ui.Success("Repository already follows profile '%s'\n", profileName)
+			return nil
+		}
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if dryRun {
+			return nil
+		}
+
+		yes, _ := cmd.Flags().GetBool("yes")
+		if !yes {
+			confirmed, err := ui.Confirm("Apply these changes", "--yes")
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				ui.Warning("Operation cancelled\n")
+				return nil
+			}
+		}
+
+		plan.ConfigFile = configFile
+		if err := plan.Execute(); err != nil {
+			return err
+		}
+
+		originURL, _ := git.GetRemoteURL(repoPath, "origin")
+		for _, change := range plan.Remotes {
+			if change.Name == "origin" {
+				originURL = change.OldURL
+			}
+		}
+		recordRepository(repoPath, originURL, profileName)
+
+		ui.OperationSuccess(fmt.Sprintf("Repository now follows profile '%s'", profileName))
+		return nil
+	},
+}
+
+// detectRepositoryProfile works out the profile of a repository from its origin
+// remote, asking the user when no URL pattern matches
+func detectRepositoryProfile(cfg *config.Config, repoPath string) (string, error) {
+	if originURL, err := git.GetRemoteURL(repoPath, "origin"); err == nil {
+		if profileName, found := git.DetectProfileForURL(originURL, cfg.Profiles); found {
+			ui.Info("Automatically detected profile: %s\n", ui.Highlight(profileName))
+			return profileName, nil
+		}
+	}
+
+	return ui.SelectFromList("Select profile", cfg.ProfileNames(), "--profile")
+}
+
+// printApplyPlan shows the changes of an apply plan as a before/after diff,
+// hiding the values the profile read from secret files
+func printApplyPlan(cfg *config.Config, plan *git.ApplyPlan) {
+	if len(plan.Remotes) > 0 {
+		ui.Info("Remotes:\n")
+		for _, change := range plan.Remotes {
+			ui.PrintChange(change.Name, change.OldURL, maskSecret(cfg, plan.ProfileName, "ssh_host", change.NewURL))
+		}
+		ui.Normal("\n")
+	}
+
+	if len(plan.Configs) > 0 || len(plan.Removals) > 0 || plan.AddInclude != "" || len(plan.RemoveIncludes) > 0 {
+		ui.Info("Local git config:\n")
+		for _, change := range plan.Configs {
+			setting := "git_configs." + change.Key
+			before := maskSecret(cfg, plan.ProfileName, setting, change.OldValue)
+			if !change.WasSet {
+				before = "(unset)"
+			}
+			ui.PrintChange(change.Key, before, maskSecret(cfg, plan.ProfileName, setting, change.NewValue))
+		}
+		for _, change := range plan.Removals {
+			ui.PrintChange(change.Key, maskSecret(cfg, plan.ProfileName, "git_configs."+change.Key, change.OldValue), "(unset)")
+		}
+		for _, include := range plan.RemoveIncludes {
+			ui.PrintChange("include.path", include, "(removed)")
+		}
+		if plan.AddInclude != "" {
+			ui.PrintChange("include.path", "(none)", plan.AddInclude)
+		}
+		ui.Normal("\n")
+	}
+
+	if plan.InstallGuard != "" {
+		ui.Info("Hooks:\n")
+		ui.PrintChange("identity guard", "(not installed)", plan.InstallGuard)
+		ui.Normal("\n")
+	}
+
+	for _, remote := range plan.Skipped {
+		ui.Warning("Skipping remote %s: unsupported URL format %s\n", remote.Name, remote.URL)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+	applyCmd.Flags().StringP("config", "c", "", "Path to config file (default is $HOME/.gclone/config.yml)")
+	applyCmd.Flags().StringP("profile", "p", "", "Profile to apply (detected from the origin remote by default)")
+	applyCmd.Flags().BoolP("yes", "y", false, "Apply the changes without confirmation")
+	applyCmd.Flags().BoolP("dry-run", "d", false, "Show the changes without applying them")
+}
+
+// maskSecret returns config.MaskedValue instead of a value that comes from a
+// profile setting (named as in config.Config.Origin) read from a secret file
+func maskSecret(cfg *config.Config, profile, setting, value string) string {
+	if value != "" && cfg.IsSecret(profile, setting) {
+		return config.MaskedValue
+	}
+	return value
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/git"
+	"github.com/user-cube/gclone/pkg/ui"
+)
+
+// auditIssuesError is returned when an audit finds repositories that do not match their profile
+type auditIssuesError struct {
+	count int
+}
+
+func (e *auditIssuesError) Error() string {
+	return fmt.Sprintf("%d repositories do not match their profile", e.count)
+}
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit [directory]",
+	Short: "Check repositories for identity and remote mismatches",
+	Long: `Walk a directory tree and check every git repository against its profile.
+The expected profile is detected from the repository's remote URL. The audit
+reports local git configs (such as user.email and user.name) that differ from
+the profile, and remotes that bypass the profile's SSH host.
+
+With --fix, the same changes as 'gclone apply' are made to every repository
+with a detected profile.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root := "."
+		if len(args) > 0 {
+			root = args[0]
+		}
+
+		output, _ := cmd.Flags().GetString("output")
+		if output != "text" && output != "json" {
+			return &usageError{err: fmt.Errorf("invalid --output %q (expected text or json)", output)}
+		}
+
+		configFile, _ := cmd.Flags().GetString("config")
+		cfg, err := config.LoadConfig(configFile)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+
+		repos, err := git.FindRepositories(root)
+		if err != nil {
+			return err
+		}
+
+		results := make([]*git.AuditResult, 0, len(repos))
+		for _, repo := range repos {
+			result, err := git.AuditRepository(repo, cfg.Profiles)
+			if err != nil {
+				return err
+			}
+			// The plan would write the profile's ${...} references as written
+			if err := cfg.ProfileError(result.Profile); result.Profile != "" && err != nil {
+				result.SkipProfile(err)
+			}
+			maskAuditResult(cfg, result)
+			results = append(results, result)
+		}
+
+		if output == "json" {
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(results); err != nil {
+				return fmt.Errorf("error encoding results: %w", err)
+			}
+		} else {
+			printAuditReport(root, results)
+		}
+
+		var mismatched, fixable []*git.AuditResult
+		for _, result := range results {
+			if len(result.Issues) > 0 {
+				mismatched = append(mismatched, result)
+			}
+			if result.Fixable() {
+				fixable = append(fixable, result)
+			}
+		}
+
+		fix, _ := cmd.Flags().GetBool("fix")
+		if !fix || len(fixable) == 0 {
+			if len(mismatched) > 0 {
+				return &auditIssuesError{count: len(mismatched)}
+			}
+			return nil
+		}
+
+		yes, _ := cmd.Flags().GetBool("yes")
+		if !yes {
+			confirmed, err := ui.Confirm(fmt.Sprintf("Fix %d repositories", len(fixable)), "--yes")
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				ui.Warning("Operation cancelled\n")
+				return &auditIssuesError{count: len(mismatched)}
+			}
+		}
+
+		for _, result := range fixable {
+			ui.Section(fmt.Sprintf("Fixing %s (%s)", result.Path, result.Profile))
+			printApplyPlan(cfg, result.Plan)
+			result.Plan.ConfigFile = configFile
+			if err := result.Plan.Execute(); err != nil {
+				return fmt.Errorf("error fixing %s: %w", result.Path, err)
+			}
+		}
+
+		ui.Success("%d repositories fixed\n", len(fixable))
+		if remaining := len(mismatched) - len(fixable); remaining > 0 {
+			return &auditIssuesError{count: remaining}
+		}
+		return nil
+	},
+}
+
+// printAuditReport prints audit results as a table, one row per issue
+func printAuditReport(root string, results []*git.AuditResult) {
+	table := ui.NewTable([]ui.TableColumn{
+		{Header: "Repository", Width: 40},
+		{Header: "Profile", Width: 12},
+		{Header: "Issue", Width: 70},
+	})
+
+	clean := 0
+	for _, result := range results {
+		path := result.Path
+		if rel, err := filepath.Rel(root, result.Path); err == nil {
+			path = rel
+		}
+
+		if len(result.Issues) == 0 {
+			clean++
+			continue
+		}
+
+		for _, issue := range result.Issues {
+			table.AddRow(path, result.Profile, describeAuditIssue(issue))
+			// Only name the repository on its first row
+			path = ""
+		}
+	}
+
+	ui.Section("Audit")
+	if len(table.Rows) > 0 {
+		table.Print()
+		ui.Normal("\n")
+	}
+	ui.Info("%d repositories checked, %d match their profile, %d have issues\n",
+		len(results), clean, len(results)-clean)
+}
+
+// maskAuditResult replaces the values the profile read from secret files in
+// the issues of a result with config.MaskedValue. The plan keeps them.
+func maskAuditResult(cfg *config.Config, result *git.AuditResult) {
+	for i, issue := range result.Issues {
+		switch issue.Kind {
+		case git.AuditConfig, git.AuditStaleConfig:
+			result.Issues[i].Actual = maskSecret(cfg, result.Profile, "git_configs."+issue.Subject, issue.Actual)
+			result.Issues[i].Expected = maskSecret(cfg, result.Profile, "git_configs."+issue.Subject, issue.Expected)
+		case git.AuditRemote:
+			result.Issues[i].Expected = maskSecret(cfg, result.Profile, "ssh_host", issue.Expected)
+		}
+	}
+}
+
+// describeAuditIssue renders an audit issue as a single line of text
+func describeAuditIssue(issue git.AuditIssue) string {
+	switch issue.Kind {
+	case git.AuditNoProfile:
+		if issue.Actual == "" {
+			return "no remote, cannot detect a profile"
+		}
+		return "no profile matches " + issue.Actual
+	case git.AuditRemote:
+		return fmt.Sprintf("remote %s bypasses SSH host: %s", issue.Subject, issue.Actual)
+	case git.AuditConfig:
+		if issue.Actual == "" {
+			return fmt.Sprintf("%s is not set, expected %q", issue.Subject, issue.Expected)
+		}
+		return fmt.Sprintf("%s is %q, expected %q", issue.Subject, issue.Actual, issue.Expected)
+	case git.AuditStaleConfig:
+		return fmt.Sprintf("%s is %q but the profile no longer sets it locally", issue.Subject, issue.Actual)
+	case git.AuditInclude:
+		if issue.Expected == "" {
+			return "stale include of " + issue.Actual
+		}
+		return "missing include of " + issue.Expected
+	case git.AuditGuard:
+		return fmt.Sprintf("identity guard (%s) is not installed", issue.Expected)
+	case git.AuditProfileError:
+		return "profile cannot be used: " + issue.Actual
+	default:
+		return issue.Kind
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.Flags().StringP("config", "c", "", "Path to config file (default is $HOME/.gclone/config.yml)")
+	auditCmd.Flags().StringP("output", "o", "text", "Output format (text, json)")
+	auditCmd.Flags().Bool("fix", false, "Apply the profile to every repository with a detected profile")
+	auditCmd.Flags().BoolP("yes", "y", false, "Fix repositories without confirmation")
+}
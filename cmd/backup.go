@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/ui"
+)
+
+// configRestoreCmd represents the config restore command
+var configRestoreCmd = &cobra.Command{
+	Use:   "restore [backup]",
+	Short: "Roll the configuration back to a backup",
+	Long: `Replace the configuration file with one of its backups. gclone backs the
+file up to ~/.gclone/backups before every change and keeps the last ten.
+The backup is its number in 'gclone config restore --list' (1 is the newest)
+or its file name; the newest is used when it is omitted. The current file is
+backed up as well, so a restore can be undone.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configFile, _ := cmd.Flags().GetString("config")
+		if configFile == "" {
+			configFile = config.DefaultConfigFile()
+		}
+
+		lock, err := config.LockConfig(configFile)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = lock.Unlock()
+		}()
+
+		backups, err := config.ListBackups(configFile)
+		if err != nil {
+			return err
+		}
+
+		if list, _ := cmd.Flags().GetBool("list"); list {
+			if len(backups) == 0 {
+				ui.Info("No backups of %s in %s\n", configFile, config.BackupDir())
+				return nil
+			}
+			table := ui.NewTable([]ui.TableColumn{
+				{Header: "#", Width: 3},
+				{Header: "Saved", Width: 20},
+				{Header: "File", Width: 45},
+			})
+			for i, b := range backups {
+				table.AddRow(strconv.Itoa(i+1), b.Time.Format("2006-01-02 15:04:05"), filepath.Base(b.Path))
+			}
+			table.Print()
+			return nil
+		}
+
+		if len(backups) == 0 {
+			return fmt.Errorf("no backups of %s in %s", configFile, config.BackupDir())
+		}
+		backup := backups[0]
+		if len(args) == 1 {
+			if backup, err = findBackup(backups, args[0]); err != nil {
+				return err
+			}
+		}
+
+		yes, _ := cmd.Flags().GetBool("yes")
+		if !yes {
+			confirmed, err := ui.Confirm(fmt.Sprintf("Restore %s from the backup saved %s", configFile, backup.Time.Format("2006-01-02 15:04:05")), "--yes")
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				ui.Warning("Operation cancelled\n")
+				return nil
+			}
+		}
+
+		if err := config.RestoreBackup(configFile, backup); err != nil {
+			return err
+		}
+		if err := regenerateProfileFiles(cmd); err != nil {
+			return err
+		}
+
+		ui.Success("Restored %s from %s\n", configFile, backup.Path)
+		return nil
+	},
+}
+
+// findBackup picks a backup by its number in the list, newest first, or by file name
+func findBackup(backups []config.Backup, name string) (config.Backup, error) {
+	if n, err := strconv.Atoi(name); err == nil {
+		if n < 1 || n > len(backups) {
+			return config.Backup{}, &usageError{err: fmt.Errorf("there is no backup %d, expected 1 to %d", n, len(backups))}
+		}
+		return backups[n-1], nil
+	}
+	for _, b := range backups {
+		if filepath.Base(b.Path) == filepath.Base(name) {
+			return b, nil
+		}
+	}
+	return config.Backup{}, &usageError{err: fmt.Errorf("no backup named %s, see 'gclone config restore --list'", name)}
+}
+
+func init() {
+	configCmd.AddCommand(configRestoreCmd)
+	configRestoreCmd.Flags().StringP("config", "c", "", "Path to config file (default is $HOME/.gclone/config.yml)")
+	configRestoreCmd.Flags().BoolP("list", "l", false, "List the backups instead of restoring one")
+	configRestoreCmd.Flags().BoolP("yes", "y", false, "Restore without confirmation")
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user-cube/gclone/pkg/git"
+	"github.com/user-cube/gclone/pkg/ui"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the local mirror cache",
+	Long: `Manage the local mirror cache used by 'gclone clone --cache'.
+Bare mirrors are kept under ~/.gclone/cache/<host>/<path>.git and refreshed
+before each cached clone.`,
+}
+
+// cacheListCmd represents the cache list command
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List cached mirrors",
+	Long:  `List the cached mirrors with their size and when they were last used.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := git.ListCache()
+		if err != nil {
+			return fmt.Errorf("error reading cache: %w", err)
+		}
+
+		if len(entries) == 0 {
+			ui.Warning("The cache is empty\n")
+			return nil
+		}
+
+		table := ui.NewTable([]ui.TableColumn{
+			{Header: "Mirror", Width: 50},
+			{Header: "Size", Width: 10},
+			{Header: "Last used", Width: 20},
+		})
+
+		var total int64
+		for _, entry := range entries {
+			table.AddRow(cacheDisplayPath(entry.Path), formatSize(entry.Size), formatLastUsed(entry.LastUsed))
+			total += entry.Size
+		}
+
+		table.Print()
+		ui.Normal("\n")
+		ui.Info("%d mirrors, %s in total\n", len(entries), formatSize(total))
+		return nil
+	},
+}
+
+// cachePruneCmd represents the cache prune command
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove cached mirrors",
+	Long: `Remove cached mirrors to keep the cache small.
+Mirrors unused for longer than --older-than are removed first, then the least
+recently used mirrors until the cache fits in --max-size.
+Clones made with --cache but without --dissociate read objects from their
+mirror and break once it is removed.
+Use --all to remove the whole cache, after confirmation.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		maxSizeFlag, _ := cmd.Flags().GetString("max-size")
+		olderThan, _ := cmd.Flags().GetDuration("older-than")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		all, _ := cmd.Flags().GetBool("all")
+
+		var maxSize int64
+		if maxSizeFlag != "" {
+			var err error
+			maxSize, err = parseSize(maxSizeFlag)
+			if err != nil {
+				return &usageError{err: fmt.Errorf("invalid --max-size: %w", err)}
+			}
+			if maxSize == 0 {
+				return &usageError{err: fmt.Errorf("--max-size must be above 0, use --all to remove the whole cache")}
+			}
+		}
+		if olderThan < 0 {
+			return &usageError{err: fmt.Errorf("--older-than must be above 0")}
+		}
+
+		switch {
+		case all && (maxSize > 0 || olderThan > 0):
+			return &usageError{err: fmt.Errorf("--all cannot be combined with --max-size or --older-than")}
+		case all:
+			// Every mirror is larger than a single byte
+			maxSize = 1
+		case maxSize == 0 && olderThan == 0:
+			return &usageError{err: fmt.Errorf("nothing to prune by, use --max-size, --older-than or --all")}
+		}
+
+		ui.Warning("Clones made with --cache but without --dissociate borrow objects from their mirror and break when it is removed\n")
+		ui.Warning("Make them independent first with 'git repack -a -d' and removing .git/objects/info/alternates\n")
+
+		yes, _ := cmd.Flags().GetBool("yes")
+		if all && !dryRun && !yes {
+			confirmed, err := ui.Confirm("Remove every mirror in "+git.CacheDir(), "--yes")
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				ui.Warning("Operation cancelled\n")
+				return nil
+			}
+		}
+
+		removed, err := git.PruneCache(maxSize, olderThan, dryRun)
+		if err != nil {
+			return fmt.Errorf("error pruning cache: %w", err)
+		}
+
+		if len(removed) == 0 {
+			ui.Success("Nothing to prune\n")
+			return nil
+		}
+
+		var freed int64
+		for _, entry := range removed {
+			if dryRun {
+				ui.Normal("  would remove %s (%s)\n", cacheDisplayPath(entry.Path), formatSize(entry.Size))
+			} else {
+				ui.Normal("  removed %s (%s)\n", cacheDisplayPath(entry.Path), formatSize(entry.Size))
+			}
+			freed += entry.Size
+		}
+
+		if dryRun {
+			ui.Info("%d mirrors would be removed, freeing %s\n", len(removed), formatSize(freed))
+		} else {
+			ui.Success("%d mirrors removed, freed %s\n", len(removed), formatSize(freed))
+		}
+
+		return nil
+	},
+}
+
+// cacheDisplayPath shows a mirror path relative to the cache directory
+func cacheDisplayPath(path string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(path, git.CacheDir()), "/")
+}
+
+// formatLastUsed renders a last used timestamp, tolerating unknown values
+func formatLastUsed(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// formatSize renders a byte count with a binary unit suffix
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// parseSize parses sizes such as 500M or 2G into bytes
+func parseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+
+	multiplier := int64(1)
+	if value != "" {
+		switch value[len(value)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			value = value[:len(value)-1]
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("expected a size such as 500M or 2G")
+	}
+
+	return int64(number * float64(multiplier)), nil
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheListCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+
+	cachePruneCmd.Flags().String("max-size", "", "Keep the cache below this size (e.g., 500M, 2G)")
+	cachePruneCmd.Flags().Duration("older-than", 0, "Remove mirrors unused for longer than this (e.g., 720h)")
+	cachePruneCmd.Flags().Bool("dry-run", false, "Show what would be removed without removing anything")
+	cachePruneCmd.Flags().Bool("all", false, "Remove every mirror")
+	cachePruneCmd.Flags().BoolP("yes", "y", false, "Remove every mirror without confirmation when using --all")
+}
@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/user-cube/gclone/pkg/config"
@@ -17,118 +19,189 @@ var cloneCmd = &cobra.Command{
 	Long: `Clone a git repository with a specific profile.
 This will transform the repository URL to use the specified SSH host,
 and apply any Git configurations specified in the profile.
-Only SSH URL format (git@github.com:user/repo.git) is supported.`,
+Only SSH URL format (git@github.com:user/repo.git) is supported.
+
+With --output json, a single JSON object describing the clone is printed on
+stdout, even when the clone fails. All other output goes to stderr.`,
 	Args: cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		// Load config
-		configFile, _ := cmd.Flags().GetString("config")
-		cfg, err := config.LoadConfig(configFile)
-		if err != nil {
-			ui.Error("Error loading configuration: %v", err)
-			return
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		if output != "text" && output != "json" {
+			return &usageError{err: fmt.Errorf("invalid --output %q (expected text or json)", output)}
 		}
 
-		if len(cfg.Profiles) == 0 {
-			ui.Warning("No profiles found in configuration. Run 'gclone init' to create default profiles.")
-			return
+		result, err := runClone(cmd, args)
+		if output == "json" {
+			result.SetError(err)
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			if encodeErr := encoder.Encode(result); encodeErr != nil && err == nil {
+				err = fmt.Errorf("error encoding result: %w", encodeErr)
+			}
 		}
 
-		// Get URL and destination
-		url := args[0]
-		var destination string
-		if len(args) > 1 {
-			destination = args[1]
+		return err
+	},
+}
+
+// runClone performs the clone command, returning a result that describes as
+// much of the clone as was done, even when it fails
+func runClone(cmd *cobra.Command, args []string) (*git.CloneResult, error) {
+	result := git.NewCloneResult(args[0], "")
+
+	// Load config
+	configFile, _ := cmd.Flags().GetString("config")
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return result, fmt.Errorf("error loading configuration: %w", err)
+	}
+
+	if len(cfg.Profiles) == 0 {
+		return result, &config.ConfigError{
+			Path: configFile,
+			Err:  fmt.Errorf("no profiles found in configuration, run 'gclone init' to create default profiles"),
 		}
+	}
 
-		// Get profile
-		profileName, _ := cmd.Flags().GetString("profile")
+	// Get URL and destination
+	url := args[0]
+	var destination string
+	if len(args) > 1 {
+		destination = args[1]
+	}
 
-		// If no profile specified, try to detect it from the URL
-		if profileName == "" {
-			detectedProfile, found := git.DetectProfileForURL(url, cfg.Profiles)
-			if found {
-				profileName = detectedProfile
-				ui.Info("Automatically detected profile: %s", ui.Highlight(profileName))
-			} else {
-				// If no profile detected, prompt user to select one
-				profileNames := make([]string, 0, len(cfg.Profiles))
-				for name := range cfg.Profiles {
-					profileNames = append(profileNames, name)
-				}
-
-				selectedProfile, err := ui.SelectFromList("Select profile", profileNames)
-				if err != nil {
-					ui.Error("Prompt failed: %v", err)
-					return
-				}
-
-				profileName = selectedProfile
+	// Get profile
+	profileName, _ := cmd.Flags().GetString("profile")
+
+	// If no profile specified, try to detect it from the URL
+	if profileName == "" {
+		detectedProfile, found := git.DetectProfileForURL(url, cfg.Profiles)
+		if found {
+			profileName = detectedProfile
+			ui.Info("Automatically detected profile: %s\n", ui.Highlight(profileName))
+		} else {
+			// If no profile detected, prompt user to select one
+			selectedProfile, err := ui.SelectFromList("Select profile", cfg.ProfileNames(), "--profile")
+			if err != nil {
+				return result, err
 			}
-		}
 
-		// Check if profile exists
-		profile, ok := cfg.Profiles[profileName]
-		if !ok {
-			ui.Error("Profile '%s' not found", profileName)
-			return
+			profileName = selectedProfile
 		}
+	}
 
-		// Collect extra git args
-		var extraArgs []string
+	// Check if profile exists
+	profile, err := cfg.GetProfile(profileName)
+	if err != nil {
+		return result, err
+	}
+	result.Profile = profileName
 
-		// Check for depth flag
-		depth, _ := cmd.Flags().GetInt("depth")
-		if depth > 0 {
-			extraArgs = append(extraArgs, fmt.Sprintf("--depth=%d", depth))
-		}
+	if err := preflightConfig(configFile, profileName); err != nil {
+		return result, err
+	}
 
-		// Check for branch flag
-		branch, _ := cmd.Flags().GetString("branch")
-		if branch != "" {
-			extraArgs = append(extraArgs, fmt.Sprintf("--branch=%s", branch))
-		}
+	// Collect extra git args
+	var extraArgs []string
 
-		// Pass through any additional flags after --
-		afterDoubleHyphen, found := findArgsAfterDoubleHyphen(os.Args)
-		if found {
-			extraArgs = append(extraArgs, afterDoubleHyphen...)
-		}
+	// Check for depth flag
+	depth, _ := cmd.Flags().GetInt("depth")
+	if depth > 0 {
+		extraArgs = append(extraArgs, fmt.Sprintf("--depth=%d", depth))
+	}
 
-		// Display information about the clone operation
-		transformedURL, _ := git.TransformGitURL(url, &profile)
+	// Check for branch flag
+	branch, _ := cmd.Flags().GetString("branch")
+	if branch != "" {
+		extraArgs = append(extraArgs, fmt.Sprintf("--branch=%s", branch))
+	}
 
-		details := map[string]string{
-			"Original URL":    url,
-			"Transformed URL": transformedURL,
-		}
+	// Pass through any additional flags after --
+	afterDoubleHyphen, found := findArgsAfterDoubleHyphen(os.Args)
+	if found {
+		extraArgs = append(extraArgs, afterDoubleHyphen...)
+	}
 
-		ui.OperationInfo("Cloning", profileName, details)
+	// Borrow objects from the local mirror cache if requested
+	var cacheUpdate *git.CacheUpdate
+	useCache, _ := cmd.Flags().GetBool("cache")
+	if useCache {
+		cacheUpdate, err = git.UpdateCache(url, &profile)
+		if err != nil {
+			ui.Warning("Cache unavailable, cloning without it: %v\n", err)
+		} else {
+			extraArgs = append(extraArgs, "--reference-if-able="+cacheUpdate.Entry.Path)
 
-		if len(profile.GitConfigs) > 0 {
-			ui.Info("Git configs to apply:")
-			for key, value := range profile.GitConfigs {
-				ui.PrintKeyValue(key, value)
+			dissociate, _ := cmd.Flags().GetBool("dissociate")
+			if dissociate {
+				extraArgs = append(extraArgs, "--dissociate")
+			} else {
+				ui.Warning("The clone borrows objects from %s and breaks if that mirror is pruned or removed, use --dissociate to make it independent\n", cacheUpdate.Entry.Path)
 			}
-			ui.Normal("\n")
 		}
+	}
 
-		// Clone the repository
-		err = git.CloneRepository(url, destination, &profile, extraArgs)
-		if err != nil {
-			ui.OperationError("cloning repository", err)
-			return
-		}
+	// Display information about the clone operation
+	transformedURL, err := git.TransformGitURL(url, &profile)
+	if err != nil {
+		return result, err
+	}
+	result.TransformedURL = transformedURL
+
+	details := map[string]string{
+		"Original URL":    url,
+		"Transformed URL": transformedURL,
+	}
 
-		repoName := destination
-		if repoName == "" {
-			repoName = git.GetRepositoryName(url)
+	ui.OperationInfo("Cloning", profileName, details)
+
+	if len(profile.GitConfigs) > 0 {
+		ui.Info("Git configs to apply:\n")
+		for key, value := range profile.GitConfigs {
+			ui.PrintKeyValue(key, maskSecret(cfg, profileName, "git_configs."+key, value))
 		}
+		ui.Normal("\n")
+	}
+
+	// Clone the repository
+	start := time.Now()
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	result, err = git.CloneRepository(url, destination, &profile, git.CloneOptions{
+		ProfileName: profileName,
+		ConfigFile:  configFile,
+		ExtraArgs:   extraArgs,
+		Verbose:     verbose,
+		Version:     Version,
+		Secret: func(key string) bool {
+			return cfg.IsSecret(profileName, "git_configs."+key)
+		},
+	})
+	if err != nil {
+		return result, fmt.Errorf("error cloning repository: %w", err)
+	}
+	cloneDuration := time.Since(start)
+
+	repoName := destination
+	if repoName == "" {
+		repoName = git.GetRepositoryName(url)
+	}
 
-		ui.OperationSuccess("Repository cloned successfully: " + repoName)
-		if len(profile.GitConfigs) > 0 {
-			ui.Success("Git configurations applied successfully")
+	ui.OperationSuccess("Repository cloned successfully: " + repoName)
+	if len(profile.GitConfigs) > 0 {
+		ui.Success("Git configurations applied successfully\n")
+	}
+
+	if cacheUpdate != nil {
+		if cacheUpdate.Created {
+			ui.Info("Cache populated in %s, later clones of this repository will be faster\n", cacheUpdate.Duration.Round(time.Millisecond))
+		} else if saved := cacheUpdate.TimeSaved(cloneDuration); saved > 0 {
+			ui.Info("Cache saved about %s compared to an uncached clone\n", saved.Round(time.Millisecond))
+		} else {
+			ui.Info("Cache refreshed, no time saved on this clone\n")
 		}
-	},
+	}
+
+	return result, nil
 }
 
 // findArgsAfterDoubleHyphen finds arguments after a -- separator
@@ -148,4 +221,8 @@ func init() {
 	cloneCmd.Flags().StringP("config", "c", "", "Path to config file (default is $HOME/.gclone/config.yml)")
 	cloneCmd.Flags().IntP("depth", "d", 0, "Create a shallow clone with the specified depth")
 	cloneCmd.Flags().StringP("branch", "b", "", "Clone the specified branch instead of the remote's HEAD")
+	cloneCmd.Flags().Bool("cache", false, "Borrow objects from a local mirror cache under ~/.gclone/cache")
+	cloneCmd.Flags().StringP("output", "o", "text", "Output format (text, json)")
+	cloneCmd.Flags().BoolP("verbose", "v", false, "Show git's raw output instead of the progress bar")
+	cloneCmd.Flags().Bool("dissociate", false, "Copy borrowed objects so the clone does not depend on the cache")
 }
@@ -1,10 +1,18 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/sshconfig"
 	"github.com/user-cube/gclone/pkg/ui"
 	"gopkg.in/yaml.v3"
 )
@@ -13,26 +21,28 @@ import (
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Display or edit the gclone configuration",
-	Long:  `Display or edit the gclone configuration.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Display the gclone configuration. Use 'gclone config edit' to change it
+and 'gclone config validate' to check it.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		configFile, _ := cmd.Flags().GetString("config")
 		if configFile == "" {
 			configFile = config.DefaultConfigFile()
 		}
 
-		// Check if config file exists
-		if _, err := os.Stat(configFile); os.IsNotExist(err) {
-			ui.Warning("Configuration file does not exist at %s", configFile)
-			ui.Warning("Run 'gclone init' to create a default configuration")
-			return
+		// Check if any config file exists
+		layers := config.ExistingLayerFiles(configFile)
+		if len(layers) == 0 {
+			ui.Warning("Configuration file does not exist at %s\n", configFile)
+			ui.Warning("Run 'gclone init' to create a default configuration\n")
+			return nil
 		}
 
-		// Load config
+		// Load config, hiding values read from secret files
 		cfg, err := config.LoadConfig(configFile)
 		if err != nil {
-			ui.Error("Error loading configuration: %v", err)
-			return
+			return fmt.Errorf("error loading configuration: %w", err)
 		}
+		cfg = cfg.Masked()
 
 		// Get format
 		format, _ := cmd.Flags().GetString("format")
@@ -42,49 +52,531 @@ var configCmd = &cobra.Command{
 			// Marshal config to YAML
 			data, err := yaml.Marshal(cfg)
 			if err != nil {
-				ui.Error("Error encoding configuration: %v", err)
-				return
+				return fmt.Errorf("error encoding configuration: %w", err)
 			}
-			ui.Normal("%s\n", string(data))
+			// The YAML is data rather than a message, keep it on stdout
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), string(data))
 		default:
-			// Display config in a user-friendly format
-			ui.Info("Configuration file: %s", configFile)
+			// Display config in a user-friendly format, with the file each value comes from
+			ui.Info("Configuration files (later ones take precedence):\n")
+			for _, layer := range layers {
+				if layer == configFile {
+					ui.Normal("  %s (written by gclone)\n", layer)
+				} else {
+					ui.Normal("  %s\n", layer)
+				}
+			}
 			ui.Normal("\n")
 
 			if len(cfg.Profiles) == 0 {
-				ui.Warning("No profiles found")
-				return
+				ui.Warning("No profiles found\n")
+				return nil
 			}
 
-			ui.Info("Profiles:")
+			ui.Info("Profiles:\n")
 			ui.Normal("\n")
 
-			for name, profile := range cfg.Profiles {
-				ui.Section("Profile: " + ui.Highlight(name))
-				ui.Normal("  Name: %s\n", profile.Name)
-				ui.Normal("  SSH Host: %s\n", profile.SSHHost)
+			names := make([]string, 0, len(cfg.Profiles))
+			for name := range cfg.Profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				profile := cfg.Profiles[name]
+				origin := func(setting string) string {
+					if file := cfg.Origin(name, setting); file != "" {
+						return "  " + ui.NewColors().Faint("("+file+")")
+					}
+					return ""
+				}
+
+				title := "Profile: " + ui.Highlight(name)
+				if profile.Abstract {
+					title += " (abstract)"
+				}
+				ui.Section(title)
+				if err := cfg.ProfileError(name); err != nil {
+					ui.Warning("  Cannot be used: %v\n", err)
+				}
+				if len(profile.Extends) > 0 {
+					ui.Normal("  Extends: %s%s\n", strings.Join(profile.Extends, ", "), origin("extends"))
+				}
+				ui.Normal("  Name: %s%s\n", profile.Name, origin("name"))
+				ui.Normal("  SSH Host: %s%s\n", profile.SSHHost, origin("ssh_host"))
+				if profile.IdentityFile != "" {
+					ui.Normal("  Identity File: %s%s\n", profile.IdentityFile, origin("identity_file"))
+				}
+				if profile.ConfigMode != "" {
+					ui.Normal("  Config Mode: %s%s\n", profile.ConfigMode, origin("config_mode"))
+				}
+				if profile.IdentityGuard != "" {
+					ui.Normal("  Identity Guard: %s%s\n", profile.IdentityGuard, origin("identity_guard"))
+				}
 
 				if len(profile.URLPatterns) > 0 {
 					ui.Normal("  URL Patterns:\n")
 					for _, pattern := range profile.URLPatterns {
-						ui.Normal("    %s\n", pattern)
+						ui.Normal("    %s%s\n", pattern, origin("url_patterns."+pattern))
 					}
 				}
 
 				if len(profile.GitConfigs) > 0 {
+					keys := make([]string, 0, len(profile.GitConfigs))
+					for key := range profile.GitConfigs {
+						keys = append(keys, key)
+					}
+					sort.Strings(keys)
+
 					ui.Normal("  Git Configs:\n")
-					for key, value := range profile.GitConfigs {
-						ui.Normal("    %s = %s\n", key, value)
+					for _, key := range keys {
+						ui.Normal("    %s = %s%s\n", key, profile.GitConfigs[key], origin("git_configs."+key))
 					}
 				}
 				ui.Normal("\n")
 			}
 		}
+
+		return nil
+	},
+}
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration for mistakes",
+	Long: `Check the configuration files (the file given with --config or the default
+one, and the system, XDG and config.d files merged with it) and report problems
+with their line numbers:
+profiles without an SSH host, invalid user.email values, malformed or unknown
+git config keys, URL patterns that clash across profiles, SSH hosts without a
+Host block in ~/.ssh/config or ~/.gclone/ssh_config, and missing identity files.
+
+Errors make the command exit with status 3; warnings alone do not.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		if output != "text" && output != "json" {
+			return &usageError{err: fmt.Errorf("invalid --output %q (expected text or json)", output)}
+		}
+
+		configFile, _ := cmd.Flags().GetString("config")
+		if configFile == "" {
+			configFile = config.DefaultConfigFile()
+		}
+
+		diagnostics, err := validateConfig(configFile)
+		if err != nil {
+			return err
+		}
+
+		if output == "json" {
+			if diagnostics == nil {
+				diagnostics = []config.Diagnostic{}
+			}
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(diagnostics); err != nil {
+				return fmt.Errorf("error encoding results: %w", err)
+			}
+		} else {
+			printDiagnostics(diagnostics)
+			if len(diagnostics) == 0 {
+				ui.Success("%s is valid\n", configFile)
+			}
+		}
+
+		if config.HasErrors(diagnostics) {
+			return &config.ConfigError{Path: configFile, Err: fmt.Errorf("configuration is invalid")}
+		}
+		return nil
 	},
 }
 
+// configEditCmd represents the config edit command
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit the configuration in your editor",
+	Long: `Open a copy of the configuration file in $VISUAL or $EDITOR (vi when neither
+is set). When the editor exits, the copy is validated as by 'gclone config
+validate'. If it has errors you can edit it again; the real file is only
+replaced once the copy is valid.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configFile, _ := cmd.Flags().GetString("config")
+		if configFile == "" {
+			configFile = config.DefaultConfigFile()
+		}
+
+		original, err := os.ReadFile(configFile)
+		existed := err == nil
+		if os.IsNotExist(err) {
+			// Start from the same profiles 'gclone init' would create
+			original, err = yaml.Marshal(config.GetDefaultConfig())
+		}
+		if err != nil {
+			return &config.ConfigError{Path: configFile, Err: fmt.Errorf("error reading config file: %w", err)}
+		}
+
+		tmpName, err := writeEditCopy(configFile, original)
+		if err != nil {
+			return err
+		}
+
+		edited, err := editUntilValid(tmpName)
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(edited, original) {
+			_ = os.Remove(tmpName)
+			ui.Info("No changes made to %s\n", configFile)
+			return nil
+		}
+
+		lock, err := config.LockConfig(configFile)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = lock.Unlock()
+		}()
+
+		// Another gclone may have saved the file while the editor was open
+		current, err := os.ReadFile(configFile)
+		if err != nil && !os.IsNotExist(err) {
+			return &config.ConfigError{Path: configFile, Err: fmt.Errorf("error reading config file: %w", err)}
+		}
+		if existed != (err == nil) || (existed && !bytes.Equal(current, original)) {
+			return &config.ConfigError{Path: configFile, Err: fmt.Errorf("file changed while you were editing, your edits are kept in %s", tmpName)}
+		}
+
+		if err := config.WriteConfigFile(configFile, edited); err != nil {
+			return err
+		}
+		_ = os.Remove(tmpName)
+
+		// Linked repositories read the generated profile files, keep them in step
+		refreshProfileFiles(configFile)
+
+		ui.Success("Configuration saved to %s\n", configFile)
+		return nil
+	},
+}
+
+// writeEditCopy writes the copy of a config file opened by 'config edit'. It
+// goes next to the file so relative ${file:...} references resolve the same.
+func writeEditCopy(configFile string, data []byte) (string, error) {
+	dir := filepath.Dir(configFile)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("error creating %s: %w", dir, err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+strings.TrimSuffix(filepath.Base(configFile), filepath.Ext(configFile))+".edit-*.yml")
+	if err != nil {
+		return "", fmt.Errorf("error creating temporary file: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("error writing temporary file: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+// editUntilValid opens a file in the user's editor until it validates or the
+// user gives up, and returns its content. The file is kept when the user gives
+// up on an invalid edit so the changes are not lost.
+func editUntilValid(file string) ([]byte, error) {
+	for {
+		if err := runEditor(file); err != nil {
+			_ = os.Remove(file)
+			return nil, err
+		}
+
+		diagnostics, err := validateConfig(file)
+		if err != nil {
+			ui.Error("%v\n", err)
+		} else {
+			printDiagnostics(diagnostics)
+			if !config.HasErrors(diagnostics) {
+				return os.ReadFile(file)
+			}
+		}
+
+		retry := false
+		if ui.IsInteractive() {
+			if retry, err = ui.Confirm("Edit again", ""); err != nil {
+				return nil, err
+			}
+		}
+		if !retry {
+			return nil, &config.ConfigError{Path: file, Err: fmt.Errorf("configuration is invalid, the real file was not changed and your edits are kept here")}
+		}
+	}
+}
+
+// runEditor opens a file in $VISUAL or $EDITOR, falling back to vi. The
+// variable may hold arguments (such as "code --wait"), so it goes through the shell.
+func runEditor(file string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	command := exec.Command("sh", "-c", editor+` "$1"`, "sh", file)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	if err := command.Run(); err != nil {
+		return fmt.Errorf("error running editor %s: %w", editor, err)
+	}
+	return nil
+}
+
+// configGetCmd represents the config get command
+var configGetCmd = &cobra.Command{
+	Use:   "get <path>",
+	Short: "Print a configuration setting",
+	Long: `Print the setting at a dotted path, for example:
+
+  gclone config get profiles.work.ssh_host
+  gclone config get profiles.work.git_configs.user.email
+
+Settings are read as written in the files; --resolved includes those inherited
+through extends and expands ${...} references, masking values read from secret
+files. Lists are printed one item per line and whole sections as
+YAML. Segments that contain dots can be double-quoted: profiles."my.work".name.
+Everything after git_configs is a single git config key and needs no quotes.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configFile, _ := cmd.Flags().GetString("config")
+		resolved, _ := cmd.Flags().GetBool("resolved")
+
+		cfg, err := loadConfigLayers(configFile, resolved)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+
+		value, err := cfg.Masked().Get(args[0])
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		switch v := value.(type) {
+		case string:
+			fmt.Fprintln(out, v)
+		case []string:
+			for _, item := range v {
+				fmt.Fprintln(out, item)
+			}
+		default:
+			data, err := yaml.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("error encoding configuration: %w", err)
+			}
+			fmt.Fprint(out, string(data))
+		}
+		return nil
+	},
+}
+
+// configSetCmd represents the config set command
+var configSetCmd = &cobra.Command{
+	Use:   "set <path> <value>",
+	Short: "Change a configuration setting",
+	Long: `Change the setting at a dotted path of an existing profile, for example:
+
+  gclone config set profiles.work.git_configs.commit.gpgsign true
+  gclone config set profiles.work.url_patterns "[github.com/acme, gitlab.com/acme]"
+  gclone config set profiles.work.config_mode include
+
+Values are checked against the setting's type: lists take a YAML flow sequence
+or a single item, git_configs a YAML mapping, and config_mode and
+identity_guard only their documented values. Use 'gclone profile add' to create
+profiles.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := updateConfigSetting(cmd, func(cfg *config.Config) error {
+			return cfg.Set(args[0], args[1])
+		})
+		if err != nil {
+			return err
+		}
+		ui.Success("Set %s\n", args[0])
+		return nil
+	},
+}
+
+// configUnsetCmd represents the config unset command
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <path>",
+	Short: "Remove a configuration setting",
+	Long: `Remove the setting at a dotted path, for example:
+
+  gclone config unset profiles.work.git_configs.commit.gpgsign
+
+Unsetting profiles.<name> removes the whole profile.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := updateConfigSetting(cmd, func(cfg *config.Config) error {
+			return cfg.Unset(args[0])
+		})
+		if err != nil {
+			return err
+		}
+		ui.Success("Unset %s\n", args[0])
+		return nil
+	},
+}
+
+// loadConfigLayers loads the configuration from every layer, resolving
+// profile inheritance only when resolved is set
+func loadConfigLayers(configFile string, resolved bool) (*config.Config, error) {
+	if resolved {
+		return config.LoadConfig(configFile)
+	}
+	user, err := config.LoadConfigFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	return config.MergeLayers(configFile, user)
+}
+
+// updateConfigSetting loads the configuration, applies a change and saves it,
+// holding the config lock throughout
+func updateConfigSetting(cmd *cobra.Command, change func(cfg *config.Config) error) error {
+	configFile, _ := cmd.Flags().GetString("config")
+	lock, err := config.LockConfig(configFile)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = lock.Unlock()
+	}()
+
+	cfg, err := config.LoadConfigFile(configFile)
+	if err != nil {
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+
+	if err := change(cfg); err != nil {
+		return err
+	}
+
+	return saveConfig(cfg, configFile)
+}
+
+// validateConfig validates a config file against the SSH configs gclone reads
+func validateConfig(configFile string) ([]config.Diagnostic, error) {
+	hosts, err := sshconfig.Load(sshconfig.DefaultFiles()...)
+	if err != nil {
+		return nil, err
+	}
+	return config.Validate(configFile, hosts)
+}
+
+// preflightConfig validates the configuration before a clone. Errors in the
+// profile being used (or in the file as a whole) stop the clone; problems
+// elsewhere only get a hint, so one broken profile does not block the others.
+func preflightConfig(configFile, profileName string) error {
+	if configFile == "" {
+		configFile = config.DefaultConfigFile()
+	}
+
+	diagnostics, err := validateConfig(configFile)
+	if err != nil {
+		return err
+	}
+
+	var blocking []config.Diagnostic
+	others := 0
+	for _, d := range diagnostics {
+		if d.Severity != config.SeverityError {
+			continue
+		}
+		if d.Profile == "" || d.Profile == profileName {
+			blocking = append(blocking, d)
+		} else {
+			others++
+		}
+	}
+
+	if others > 0 {
+		ui.Warning("Other profiles have %d configuration errors, run 'gclone config validate' for details\n", others)
+	}
+	if len(blocking) > 0 {
+		printDiagnostics(blocking)
+		return &config.ConfigError{Path: configFile, Err: fmt.Errorf("profile '%s' is invalid, fix the errors above and retry", profileName)}
+	}
+	return nil
+}
+
+// printDiagnostics prints validation problems as file:line:column messages
+func printDiagnostics(diagnostics []config.Diagnostic) {
+	for _, d := range diagnostics {
+		if d.Severity == config.SeverityError {
+			ui.Error("%s\n", d)
+		} else {
+			ui.Warning("%s\n", d)
+		}
+	}
+}
+
+// saveConfig writes a configuration loaded with config.LoadConfigFile and
+// regenerates the git config files of the profiles, so repositories linking them through include.path pick up the change
+func saveConfig(cfg *config.Config, configFile string) error {
+	// A change that breaks inheritance would leave a file no command can load
+	resolved, err := config.MergeLayers(configFile, cfg)
+	if err != nil {
+		return err
+	}
+	if err := resolved.Resolve(); err != nil {
+		return &config.ConfigError{Path: configFile, Err: fmt.Errorf("change not saved: %w", err)}
+	}
+	if err := config.SaveConfig(cfg, configFile); err != nil {
+		return fmt.Errorf("error saving configuration: %w", err)
+	}
+
+	refreshProfileFiles(configFile)
+	return nil
+}
+
+// refreshProfileFiles regenerates the profile git config files after the
+// configuration was saved. They hold the values with their ${...} references
+// expanded, which may fail for a variable that is only set later. The change
+// stands either way, so problems are warnings rather than command errors.
+func refreshProfileFiles(configFile string) {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		ui.Warning("Configuration saved, but the profile git config files were not regenerated: %v\n", err)
+		return
+	}
+	if err := writeProfileFiles(cfg); err != nil {
+		ui.Warning("Configuration saved, but %v\n", err)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(configCmd)
 	configCmd.Flags().StringP("config", "c", "", "Path to config file (default is $HOME/.gclone/config.yml)")
 	configCmd.Flags().StringP("format", "f", "pretty", "Output format (pretty, yaml)")
+
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configEditCmd)
+	configEditCmd.Flags().StringP("config", "c", "", "Path to config file (default is $HOME/.gclone/config.yml)")
+
+	for _, c := range []*cobra.Command{configGetCmd, configSetCmd, configUnsetCmd} {
+		configCmd.AddCommand(c)
+		c.Flags().StringP("config", "c", "", "Path to config file (default is $HOME/.gclone/config.yml)")
+	}
+	configGetCmd.Flags().BoolP("resolved", "r", false, "Include settings inherited through extends")
+	configValidateCmd.Flags().StringP("config", "c", "", "Path to config file (default is $HOME/.gclone/config.yml)")
+	configValidateCmd.Flags().StringP("output", "o", "text", "Output format (text, json)")
 }
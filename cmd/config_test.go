@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/user-cube/gclone/pkg/config"
+)
+
+func TestEditCopyResolvesRelativeSecretFiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yml")
+	if err := os.WriteFile(filepath.Join(dir, "email"), []byte("me@example.com\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(`version: 1
+profiles:
+  work:
+    name: Work
+    ssh_host: alias-work
+    git_configs:
+      user.email: ${file:email}
+`)
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpName, err := writeEditCopy(configFile, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpName)
+
+	diagnostics, err := validateConfig(tmpName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range diagnostics {
+		if d.Severity == config.SeverityError {
+			t.Errorf("validateConfig() reported %s, want the copy to validate like the file", d)
+		}
+	}
+}
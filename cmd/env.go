@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/git"
+	"github.com/user-cube/gclone/pkg/sshconfig"
+	"github.com/user-cube/gclone/pkg/ui"
+)
+
+// commandExitError carries the exit status of a command run by gclone exec,
+// which gclone exits with instead of reporting an error of its own. A command
+// killed by a signal has no status (code is -1) and is reported as failed.
+type commandExitError struct {
+	code int
+	err  error
+}
+
+func (e *commandExitError) Error() string {
+	if e.code < 0 {
+		return fmt.Sprintf("command did not exit: %v", e.err)
+	}
+	return fmt.Sprintf("command exited with status %d", e.code)
+}
+
+// killed reports whether the command was stopped by a signal
+func (e *commandExitError) killed() bool {
+	return e.code < 0
+}
+
+// envCmd represents the env command
+var envCmd = &cobra.Command{
+	Use:   "env <profile>",
+	Short: "Print shell exports for a profile identity",
+	Long: `Print shell exports that make git act as a profile in any repository,
+including ones gclone did not clone:
+
+  eval "$(gclone env work)"
+
+The author and committer come from the profile's user.name and user.email,
+GIT_SSH_COMMAND is set for profiles with an identity_file, and every other git
+config is passed through GIT_CONFIG_COUNT/GIT_CONFIG_KEY_n/GIT_CONFIG_VALUE_n.
+For profiles with an SSH host alias, URLs of the real host are rewritten to the
+alias with url.<alias>.insteadOf.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vars, err := profileEnv(cmd, args[0])
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		for _, v := range vars {
+			fmt.Fprintf(out, "export %s=%s\n", v.Name, git.ShellQuote(v.Value))
+		}
+		return nil
+	},
+}
+
+// execCmd represents the exec command
+var execCmd = &cobra.Command{
+	Use:   "exec <profile> -- <command> [args...]",
+	Short: "Run a command under a profile identity",
+	Long: `Run a command with the environment printed by 'gclone env' applied, for example:
+
+  gclone exec work -- git push origin main
+
+Flags for gclone itself go before the profile; everything after it belongs to
+the command. gclone exits with the command's exit status.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Flag parsing stops at the profile, so the separator is still in args
+		if args[1] == "--" {
+			args = append(args[:1], args[2:]...)
+		}
+		if len(args) < 2 {
+			return &usageError{err: fmt.Errorf("no command given")}
+		}
+
+		vars, err := profileEnv(cmd, args[0])
+		if err != nil {
+			return err
+		}
+
+		command := exec.Command(args[1], args[2:]...)
+		command.Env = git.ApplyEnv(os.Environ(), vars)
+		command.Stdin = os.Stdin
+		command.Stdout = cmd.OutOrStdout()
+		command.Stderr = os.Stderr
+
+		if err := command.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				return &commandExitError{code: exitErr.ExitCode(), err: err}
+			}
+			return fmt.Errorf("error running %s: %w", args[1], err)
+		}
+		return nil
+	},
+}
+
+// profileEnv loads a profile and returns its environment, reporting any warnings on stderr
+func profileEnv(cmd *cobra.Command, profileName string) ([]git.EnvVar, error) {
+	configFile, _ := cmd.Flags().GetString("config")
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading configuration: %w", err)
+	}
+
+	profile, err := cfg.GetProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts, err := sshconfig.Load(sshconfig.DefaultFiles()...)
+	if err != nil {
+		return nil, err
+	}
+
+	vars, warnings := git.ProfileEnv(&profile, hosts)
+	for _, warning := range warnings {
+		ui.Warning("%s\n", warning)
+	}
+	return vars, nil
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+	rootCmd.AddCommand(execCmd)
+	envCmd.Flags().StringP("config", "c", "", "Path to config file (default is $HOME/.gclone/config.yml)")
+	execCmd.Flags().StringP("config", "c", "", "Path to config file (default is $HOME/.gclone/config.yml)")
+
+	// Flags after the profile belong to the command being run
+	execCmd.Flags().SetInterspersed(false)
+}
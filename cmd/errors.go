@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/git"
+	"github.com/user-cube/gclone/pkg/ui"
+)
+
+// Exit codes returned by gclone. These are part of the CLI contract and are
+// documented in the README, so existing values must never change meaning.
+const (
+	// ExitOK means the command succeeded
+	ExitOK = 0
+	// ExitError is used for failures without a more specific code
+	ExitError = 1
+	// ExitUsage means the command line was invalid
+	ExitUsage = 2
+	// ExitConfig means the configuration could not be read, parsed or written
+	ExitConfig = 3
+	// ExitProfileNotFound means the requested profile does not exist
+	ExitProfileNotFound = 4
+	// ExitUnsupportedURL means the repository URL format is not supported
+	ExitUnsupportedURL = 5
+	// ExitGitFailed means a git command failed
+	ExitGitFailed = 6
+	// ExitInputRequired means a prompt was needed in non-interactive mode
+	ExitInputRequired = 7
+	// ExitAuditIssues means an audit found repositories that do not match their profile
+	ExitAuditIssues = 8
+)
+
+// usageError marks errors caused by invalid arguments or flags
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *usageError) Unwrap() error {
+	return e.err
+}
+
+// exitCode maps an error returned by a command to the process exit code
+func exitCode(err error) int {
+	var (
+		usageErr          *usageError
+		configErr         *config.ConfigError
+		profileErr        *config.ProfileNotFoundError
+		pathErr           *config.PathError
+		abstractErr       *config.AbstractProfileError
+		urlErr            *git.UnsupportedURLError
+		gitErr            *git.GitError
+		nonInteractiveErr *ui.NonInteractiveError
+		auditErr          *auditIssuesError
+		commandErr        *commandExitError
+	)
+
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &commandErr) && commandErr.killed():
+		return ExitGitFailed
+	case errors.As(err, &commandErr):
+		return commandErr.code
+	case errors.As(err, &usageErr), errors.As(err, &pathErr), errors.As(err, &abstractErr):
+		return ExitUsage
+	case errors.As(err, &nonInteractiveErr):
+		return ExitInputRequired
+	case errors.As(err, &profileErr):
+		return ExitProfileNotFound
+	case errors.As(err, &urlErr):
+		return ExitUnsupportedURL
+	case errors.As(err, &gitErr):
+		return ExitGitFailed
+	case errors.As(err, &configErr):
+		return ExitConfig
+	case errors.As(err, &auditErr):
+		return ExitAuditIssues
+	default:
+		return ExitError
+	}
+}
+
+// unknownCommand rejects arguments given to a command that only groups
+// subcommands, as cobra does, suggesting the closest subcommand names
+func unknownCommand(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("unknown command %q for %q", args[0], cmd.CommandPath())
+	if suggestions := cmd.SuggestionsFor(args[0]); len(suggestions) > 0 {
+		msg += "\n\nDid you mean this?\n\t" + strings.Join(suggestions, "\n\t")
+	}
+	return errors.New(msg)
+}
+
+// markUsageErrors wraps the positional argument validation of a command tree
+// so invalid arguments are reported with ExitUsage
+func markUsageErrors(c *cobra.Command) {
+	if validate := c.Args; validate != nil {
+		c.Args = func(cmd *cobra.Command, args []string) error {
+			if err := validate(cmd, args); err != nil {
+				return &usageError{err: err}
+			}
+			return nil
+		}
+	}
+
+	for _, sub := range c.Commands() {
+		markUsageErrors(sub)
+	}
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	markUsageErrors(rootCmd)
+
+	var killed *exec.ExitError
+	if err := exec.Command("sh", "-c", "kill -9 $$").Run(); !errors.As(err, &killed) {
+		t.Fatalf("expected the shell to be killed, got %v", err)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, ExitOK},
+		{"unknown command", rootCmd.ValidateArgs([]string{"nope"}), ExitUsage},
+		{"wrong arguments", cloneCmd.ValidateArgs(nil), ExitUsage},
+		{"unknown flag", rootCmd.FlagErrorFunc()(rootCmd, errors.New("unknown flag: --nope")), ExitUsage},
+		{"command status", &commandExitError{code: 3}, 3},
+		{"command killed", &commandExitError{code: killed.ExitCode(), err: killed}, ExitGitFailed},
+		{"other", errors.New("boom"), ExitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
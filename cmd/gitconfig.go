@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/git"
+	"github.com/user-cube/gclone/pkg/ui"
+)
+
+// gitconfigCmd represents the gitconfig command
+var gitconfigCmd = &cobra.Command{
+	Use:   "gitconfig",
+	Short: "Manage includeIf blocks in the global git config",
+	Long: `Manage a gclone section in the global git config (~/.gitconfig) that uses git's
+native includeIf "gitdir:..." blocks. Every directory in a profile's 'roots'
+is mapped to a file generated from the profile's git_configs, so every
+repository below it picks up the profile without any per-repository config.`,
+}
+
+// gitconfigInstallCmd represents the gitconfig install command
+var gitconfigInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Write the includeIf blocks of all profiles",
+	Long: `Write the gclone section of the global git config, replacing the previous one.
+The section is clearly marked and only contains includeIf blocks for profiles
+with 'roots'. The previous version of the file is kept in ~/.gclone/backups,
+which holds the last 10 versions. Running the command again after editing profiles
+updates the section.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configFile, _ := cmd.Flags().GetString("config")
+		cfg, err := config.LoadConfig(configFile)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+
+		for _, name := range cfg.ProfileNames() {
+			if len(cfg.Profiles[name].Roots) == 0 {
+				ui.Warning("Profile '%s' has no roots, skipping\n", name)
+			}
+		}
+
+		file := gitconfigFile(cmd)
+		section := git.RenderIncludeIfSection(cfg)
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if dryRun {
+			ui.Info("The following section would be written to %s:\n\n", file)
+			ui.Normal("%s", section)
+			return nil
+		}
+
+		if err := writeProfileFiles(cfg); err != nil {
+			return err
+		}
+
+		backup, err := git.InstallIncludeIf(file, section)
+		if err != nil {
+			return err
+		}
+		if backup == "" {
+			ui.Success("%s is already up to date\n", file)
+			return nil
+		}
+
+		ui.Success("includeIf blocks written to %s\n", file)
+		ui.Info("Previous version saved to %s\n", backup)
+		return nil
+	},
+}
+
+// gitconfigUninstallCmd represents the gitconfig uninstall command
+var gitconfigUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove the includeIf blocks written by gclone",
+	Long: `Remove the gclone section from the global git config. The rest of the file is
+left untouched and the previous version is kept in ~/.gclone/backups.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file := gitconfigFile(cmd)
+
+		backup, err := git.UninstallIncludeIf(file)
+		if err != nil {
+			return err
+		}
+		if backup == "" {
+			ui.Info("No gclone section found in %s\n", file)
+			return nil
+		}
+
+		ui.Success("gclone section removed from %s\n", file)
+		ui.Info("Previous version saved to %s\n", backup)
+		return nil
+	},
+}
+
+// gitconfigFile returns the git config file selected with --file
+func gitconfigFile(cmd *cobra.Command) string {
+	file, _ := cmd.Flags().GetString("file")
+	if file == "" {
+		return git.GlobalConfigFile()
+	}
+	return file
+}
+
+func init() {
+	rootCmd.AddCommand(gitconfigCmd)
+	gitconfigCmd.AddCommand(gitconfigInstallCmd)
+	gitconfigCmd.AddCommand(gitconfigUninstallCmd)
+
+	gitconfigCmd.PersistentFlags().String("file", "", "Git config file to manage (default is $HOME/.gitconfig)")
+	gitconfigInstallCmd.Flags().StringP("config", "c", "", "Path to config file (default is $HOME/.gclone/config.yml)")
+	gitconfigInstallCmd.Flags().BoolP("dry-run", "d", false, "Show the section without writing it")
+}
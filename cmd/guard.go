@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/git"
+	"github.com/user-cube/gclone/pkg/ui"
+)
+
+// guardCmd represents the guard command
+var guardCmd = &cobra.Command{
+	Use:   "guard",
+	Short: "Manage the identity guard git hook",
+	Long: `Manage the identity guard, a git hook that blocks commits whose author or
+committer email does not match the repository's profile. The email must equal
+the profile's user.email and, when allowed_email_domains is set, belong to one
+of those domains.
+
+Profiles with 'identity_guard' set get the guard installed automatically on
+clone, apply, switch and sync.`,
+}
+
+// guardInstallCmd represents the guard install command
+var guardInstallCmd = &cobra.Command{
+	Use:   "install [path]",
+	Short: "Install the identity guard in a repository",
+	Long: `Install the identity guard in a repository (the current directory by default).
+
+With --mode pre-commit, a pre-commit hook is written to the repository's hooks
+directory; an existing pre-commit hook is kept and run after the check.
+With --mode hooks-path, core.hooksPath is pointed at ~/.gclone/hooks, whose
+hooks run the check and then the hooks the repository used before.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := "."
+		if len(args) > 0 {
+			path = args[0]
+		}
+		repoPath, err := git.FindRepositoryRoot(path)
+		if err != nil {
+			return err
+		}
+
+		configFile, _ := cmd.Flags().GetString("config")
+		mode, _ := cmd.Flags().GetString("mode")
+		if mode == "" {
+			mode = config.GuardPreCommit
+			if cfg, err := config.LoadConfig(configFile); err == nil {
+				if profile, err := cfg.GetProfile(currentRepositoryProfile(cfg, repoPath)); err == nil && profile.IdentityGuard != "" {
+					mode = profile.IdentityGuard
+				}
+			}
+		}
+		if mode != config.GuardPreCommit && mode != config.GuardHooksPath {
+			return &usageError{err: fmt.Errorf("invalid --mode %q (expected %s or %s)", mode, config.GuardPreCommit, config.GuardHooksPath)}
+		}
+
+		if err := git.InstallGuard(repoPath, mode, configFile); err != nil {
+			return err
+		}
+
+		ui.Success("Identity guard (%s) installed in %s\n", mode, repoPath)
+		return nil
+	},
+}
+
+// guardUninstallCmd represents the guard uninstall command
+var guardUninstallCmd = &cobra.Command{
+	Use:   "uninstall [path]",
+	Short: "Remove the identity guard from a repository",
+	Long: `Remove the identity guard from a repository (the current directory by default)
+and restore the hooks it chained.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := "."
+		if len(args) > 0 {
+			path = args[0]
+		}
+		repoPath, err := git.FindRepositoryRoot(path)
+		if err != nil {
+			return err
+		}
+
+		if err := git.UninstallGuard(repoPath); err != nil {
+			return err
+		}
+
+		ui.Success("Identity guard removed from %s\n", repoPath)
+		return nil
+	},
+}
+
+// guardCheckCmd is run by the identity guard hook before every commit
+var guardCheckCmd = &cobra.Command{
+	Use:    "check",
+	Short:  "Check the commit identity against the repository's profile",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repoPath, err := git.FindRepositoryRoot(".")
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.LoadConfig(git.GuardConfigFile(repoPath))
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+
+		// Repositories without a known profile have nothing to check against
+		profileName := currentRepositoryProfile(cfg, repoPath)
+		profile, err := cfg.GetProfile(profileName)
+		if err != nil {
+			return nil
+		}
+
+		return git.CheckIdentity(repoPath, profileName, &profile)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(guardCmd)
+	guardCmd.AddCommand(guardInstallCmd)
+	guardCmd.AddCommand(guardUninstallCmd)
+	guardCmd.AddCommand(guardCheckCmd)
+
+	guardInstallCmd.Flags().StringP("config", "c", "", "Path to config file the guard reads (default is $HOME/.gclone/config.yml)")
+	guardInstallCmd.Flags().StringP("mode", "m", "", "How to install the hook: pre-commit or hooks-path (default is the profile's identity_guard, or pre-commit)")
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 	"strings"
 
@@ -15,37 +16,44 @@ var initCmd = &cobra.Command{
 	Short: "Initialize the gclone configuration",
 	Long: `Initialize the gclone configuration file with default settings.
 This will create a configuration file at ~/.gclone/config.yml with sample profiles.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		configFile := config.DefaultConfigFile()
 		configDir := config.DefaultConfigDir()
 
+		lock, err := config.LockConfig(configFile)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = lock.Unlock()
+		}()
+
 		// Check if config file already exists
 		if _, err := os.Stat(configFile); err == nil {
 			overwrite, _ := cmd.Flags().GetBool("force")
 			if !overwrite {
-				ui.Warning("Configuration file already exists at %s", configFile)
-				ui.Warning("Use --force to overwrite the existing configuration")
-				return
+				return &config.ConfigError{
+					Path: configFile,
+					Err:  fmt.Errorf("configuration file already exists, use --force to overwrite it"),
+				}
 			}
 		}
 
 		// Ensure directory exists
 		if err := os.MkdirAll(configDir, 0755); err != nil {
-			ui.Error("Error creating config directory: %v", err)
-			return
+			return &config.ConfigError{Path: configDir, Err: fmt.Errorf("error creating config directory: %w", err)}
 		}
 
 		// Get default config
 		cfg := config.GetDefaultConfig()
 
 		// Save config
-		if err := config.SaveConfig(cfg, configFile); err != nil {
-			ui.Error("Error saving configuration: %v", err)
-			return
+		if err := saveConfig(cfg, configFile); err != nil {
+			return err
 		}
 
-		ui.Success("Configuration initialized successfully at %s", configFile)
-		ui.Info("Default profiles created:")
+		ui.Success("Configuration initialized successfully at %s\n", configFile)
+		ui.Info("Default profiles created:\n")
 		for name, profile := range cfg.Profiles {
 			ui.Normal("  - %s (SSH Host: %s)\n", ui.Highlight(name), profile.SSHHost)
 
@@ -84,6 +92,8 @@ This will create a configuration file at ~/.gclone/config.yml with sample profil
 		ui.Section("SSH Setup Helper")
 		ui.Info("Alternatively, you can use the ssh-config command to set up your SSH configuration:")
 		ui.Normal("  gclone ssh-config personal\n")
+
+		return nil
 	},
 }
 
@@ -2,10 +2,12 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/user-cube/gclone/pkg/config"
 	"github.com/user-cube/gclone/pkg/ui"
+	"gopkg.in/yaml.v3"
 )
 
 // profileCmd represents the profile command
@@ -20,25 +22,38 @@ var profileListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all profiles",
 	Long:  `List all profiles in the gclone configuration.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		configFile, _ := cmd.Flags().GetString("config")
 		cfg, err := config.LoadConfig(configFile)
 		if err != nil {
-			ui.Error("Error loading configuration: %v", err)
-			return
+			return fmt.Errorf("error loading configuration: %w", err)
 		}
+		cfg = cfg.Masked()
 
 		if len(cfg.Profiles) == 0 {
-			ui.Warning("No profiles found. Run 'gclone init' to create default profiles.")
-			return
+			ui.Warning("No profiles found. Run 'gclone init' to create default profiles.\n")
+			return nil
 		}
 
 		ui.Section("Available profiles")
 
 		for name, profile := range cfg.Profiles {
-			ui.Info("Profile: %s", ui.Highlight(name))
+			if profile.Abstract {
+				ui.Info("Profile: %s (abstract)\n", ui.Highlight(name))
+			} else {
+				ui.Info("Profile: %s\n", ui.Highlight(name))
+			}
+			if err := cfg.ProfileError(name); err != nil {
+				ui.Warning("  Cannot be used: %v\n", err)
+			}
 			ui.PrintKeyValue("Name", profile.Name)
+			if len(profile.Extends) > 0 {
+				ui.PrintKeyValue("Extends", strings.Join(profile.Extends, ", "))
+			}
 			ui.PrintKeyValue("SSH Host", profile.SSHHost)
+			if profile.IdentityFile != "" {
+				ui.PrintKeyValue("Identity File", profile.IdentityFile)
+			}
 
 			if len(profile.URLPatterns) > 0 {
 				ui.Normal("  URL Patterns:\n")
@@ -55,6 +70,40 @@ var profileListCmd = &cobra.Command{
 			}
 			ui.Normal("\n")
 		}
+
+		return nil
+	},
+}
+
+// profileShowCmd represents the profile show command
+var profileShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Print a profile",
+	Long: `Print a profile as YAML, as it is written in the configuration files.
+With --resolved, the profile is printed merged with the profiles it extends
+and with ${...} references expanded, which is what gclone uses. Values read
+from secret files are masked.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configFile, _ := cmd.Flags().GetString("config")
+		resolved, _ := cmd.Flags().GetBool("resolved")
+
+		cfg, err := loadConfigLayers(configFile, resolved)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+
+		profile, exists := cfg.Masked().Profiles[args[0]]
+		if !exists {
+			return &config.ProfileNotFoundError{Name: args[0]}
+		}
+
+		data, err := yaml.Marshal(profile)
+		if err != nil {
+			return fmt.Errorf("error encoding profile: %w", err)
+		}
+		fmt.Fprint(cmd.OutOrStdout(), string(data))
+		return nil
 	},
 }
 
@@ -65,12 +114,19 @@ var profileAddCmd = &cobra.Command{
 	Long: `Add a new profile to the gclone configuration. 
 If no name is provided, you'll be guided through an interactive profile creation process.`,
 	Args: cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		configFile, _ := cmd.Flags().GetString("config")
-		cfg, err := config.LoadConfig(configFile)
+		lock, err := config.LockConfig(configFile)
 		if err != nil {
-			ui.Error("Error loading configuration: %v", err)
-			return
+			return err
+		}
+		defer func() {
+			_ = lock.Unlock()
+		}()
+
+		cfg, err := config.LoadConfigFile(configFile)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
 		}
 
 		var profileName string
@@ -82,25 +138,33 @@ If no name is provided, you'll be guided through an interactive profile creation
 				if input == "" {
 					return fmt.Errorf("profile name cannot be empty")
 				}
-				return nil
-			})
+				return config.ValidateProfileName(input)
+			}, "the profile name argument")
 			if err != nil {
-				ui.Error("Error getting profile name: %v", err)
-				return
+				return fmt.Errorf("error getting profile name: %w", err)
 			}
 		} else {
 			profileName = args[0]
+			if err := config.ValidateProfileName(profileName); err != nil {
+				return &usageError{err: err}
+			}
 		}
 
 		// Check if profile already exists
 		if _, exists := cfg.Profiles[profileName]; exists {
-			ui.Warning("Profile '%s' already exists", profileName)
+			ui.Warning("Profile '%s' already exists\n", profileName)
 
 			// Confirm overwrite
-			confirmed, err := ui.Confirm("Do you want to overwrite it")
-			if err != nil || !confirmed {
-				ui.Warning("Operation cancelled")
-				return
+			force, _ := cmd.Flags().GetBool("force")
+			if !force {
+				confirmed, err := ui.Confirm("Do you want to overwrite it", "--force")
+				if err != nil {
+					return err
+				}
+				if !confirmed {
+					ui.Warning("Operation cancelled\n")
+					return nil
+				}
 			}
 		}
 
@@ -113,20 +177,23 @@ If no name is provided, you'll be guided through an interactive profile creation
 		} else if len(args) == 0 {
 			// If in interactive mode and no name flag, prompt for display name
 			var err error
-			name, err = ui.PromptInput("Display name", profileName, nil)
+			name, err = ui.PromptInput("Display name", profileName, nil, "--name")
 			if err != nil {
-				ui.Error("Error getting display name: %v", err)
-				return
+				return fmt.Errorf("error getting display name: %w", err)
 			}
 		} else {
 			name = profileName
 		}
 
-		// Get SSH host
+		// Get SSH host; a profile with an identity file keeps the original host,
+		// and one extending other profiles can inherit it
 		var sshHost string
 		sshHostFlag, _ := cmd.Flags().GetString("ssh-host")
+		identityFile, _ := cmd.Flags().GetString("identity-file")
+		extends, _ := cmd.Flags().GetStringArray("extends")
+		abstract, _ := cmd.Flags().GetBool("abstract")
 
-		if sshHostFlag != "" {
+		if sshHostFlag != "" || identityFile != "" || len(extends) > 0 || abstract {
 			sshHost = sshHostFlag
 		} else {
 			// Prompt for SSH host
@@ -136,10 +203,9 @@ If no name is provided, you'll be guided through an interactive profile creation
 					return fmt.Errorf("SSH host cannot be empty")
 				}
 				return nil
-			})
+			}, "--ssh-host")
 			if err != nil {
-				ui.Error("Error getting SSH host: %v", err)
-				return
+				return fmt.Errorf("error getting SSH host: %w", err)
 			}
 		}
 
@@ -150,6 +216,28 @@ If no name is provided, you'll be guided through an interactive profile creation
 			GitConfigs:  make(map[string]string),
 			URLPatterns: []string{},
 		}
+		profile.IdentityFile = identityFile
+		profile.Extends = extends
+		profile.Abstract = abstract
+		profile.SSHOptions, _ = cmd.Flags().GetStringArray("ssh-option")
+		profile.AllowedEmailDomains, _ = cmd.Flags().GetStringArray("allowed-email-domain")
+
+		identityGuard, _ := cmd.Flags().GetString("identity-guard")
+		switch identityGuard {
+		case "", config.GuardPreCommit, config.GuardHooksPath:
+			profile.IdentityGuard = identityGuard
+		default:
+			return &usageError{err: fmt.Errorf("invalid --identity-guard %q (expected %s or %s)", identityGuard, config.GuardPreCommit, config.GuardHooksPath)}
+		}
+
+		configMode, _ := cmd.Flags().GetString("config-mode")
+		switch configMode {
+		case "", config.ConfigModeCopy:
+		case config.ConfigModeInclude:
+			profile.ConfigMode = configMode
+		default:
+			return &usageError{err: fmt.Errorf("invalid --config-mode %q (expected copy or include)", configMode)}
+		}
 
 		// Get URL patterns
 		urlPatterns, _ := cmd.Flags().GetStringArray("url-pattern")
@@ -157,10 +245,10 @@ If no name is provided, you'll be guided through an interactive profile creation
 			profile.URLPatterns = urlPatterns
 		} else if len(args) == 0 {
 			// If in interactive mode, ask if user wants to add URL patterns
-			addPatterns, err := ui.Confirm("Do you want to add URL patterns for automatic profile detection")
+			addPatterns, err := ui.Confirm("Do you want to add URL patterns for automatic profile detection", "--url-pattern")
 			if err == nil && addPatterns {
 				for {
-					pattern, err := ui.PromptInput("URL pattern (leave empty to stop)", "", nil)
+					pattern, err := ui.PromptInput("URL pattern (leave empty to stop)", "", nil, "--url-pattern")
 					if err != nil || pattern == "" {
 						break
 					}
@@ -177,7 +265,7 @@ If no name is provided, you'll be guided through an interactive profile creation
 			profile.GitConfigs["user.name"] = gitUsername
 		} else if len(args) == 0 {
 			// If in interactive mode, prompt for Git username
-			username, err := ui.PromptInput("Git username (leave empty to skip)", "", nil)
+			username, err := ui.PromptInput("Git username (leave empty to skip)", "", nil, "--git-username")
 			if err == nil && username != "" {
 				profile.GitConfigs["user.name"] = username
 			}
@@ -187,25 +275,34 @@ If no name is provided, you'll be guided through an interactive profile creation
 			profile.GitConfigs["user.email"] = gitEmail
 		} else if len(args) == 0 {
 			// If in interactive mode, prompt for Git email
-			email, err := ui.PromptInput("Git email (leave empty to skip)", "", nil)
+			email, err := ui.PromptInput("Git email (leave empty to skip)", "", nil, "--git-email")
 			if err == nil && email != "" {
 				profile.GitConfigs["user.email"] = email
 			}
 		}
 
+		gitConfigs, _ := cmd.Flags().GetStringArray("git-config")
+		for _, entry := range gitConfigs {
+			key, value, ok := strings.Cut(entry, "=")
+			if !ok || key == "" {
+				return &usageError{err: fmt.Errorf("invalid --git-config %q, expected key=value", entry)}
+			}
+			profile.GitConfigs[key] = value
+		}
+
 		// If in interactive mode, ask if user wants to add additional Git configurations
-		if len(args) == 0 {
-			addGitConfigs, err := ui.Confirm("Do you want to add additional Git configurations")
+		if len(args) == 0 && len(gitConfigs) == 0 {
+			addGitConfigs, err := ui.Confirm("Do you want to add additional Git configurations", "--git-config key=value")
 			if err == nil && addGitConfigs {
 				for {
-					key, err := ui.PromptInput("Git config key (e.g., commit.gpgsign, leave empty to stop)", "", nil)
+					key, err := ui.PromptInput("Git config key (e.g., commit.gpgsign, leave empty to stop)", "", nil, "--git-config key=value")
 					if err != nil || key == "" {
 						break
 					}
 
-					value, err := ui.PromptInput("Value for "+key, "", nil)
+					value, err := ui.PromptInput("Value for "+key, "", nil, "--git-config "+key+"=value")
 					if err != nil {
-						ui.Warning("Error getting config value, skipping")
+						ui.Warning("Error getting config value, skipping\n")
 						continue
 					}
 
@@ -216,12 +313,12 @@ If no name is provided, you'll be guided through an interactive profile creation
 
 		// Save profile
 		cfg.Profiles[profileName] = profile
-		if err := config.SaveConfig(cfg, configFile); err != nil {
-			ui.Error("Error saving configuration: %v", err)
-			return
+		if err := saveConfig(cfg, configFile); err != nil {
+			return err
 		}
 
-		ui.Success("Profile '%s' added successfully", profileName)
+		ui.Success("Profile '%s' added successfully\n", profileName)
+		return nil
 	},
 }
 
@@ -231,40 +328,49 @@ var profileRemoveCmd = &cobra.Command{
 	Short: "Remove a profile",
 	Long:  `Remove a profile from the gclone configuration.`,
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		profileName := args[0]
 
 		configFile, _ := cmd.Flags().GetString("config")
-		cfg, err := config.LoadConfig(configFile)
+		lock, err := config.LockConfig(configFile)
 		if err != nil {
-			ui.Error("Error loading configuration: %v", err)
-			return
+			return err
+		}
+		defer func() {
+			_ = lock.Unlock()
+		}()
+
+		cfg, err := config.LoadConfigFile(configFile)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
 		}
 
 		// Check if profile exists
 		if _, exists := cfg.Profiles[profileName]; !exists {
-			ui.Error("Profile '%s' does not exist", profileName)
-			return
+			return &config.ProfileNotFoundError{Name: profileName}
 		}
 
 		// Confirm removal
 		force, _ := cmd.Flags().GetBool("force")
 		if !force {
-			confirmed, err := ui.Confirm("Are you sure you want to remove profile '" + profileName + "'")
-			if err != nil || !confirmed {
-				ui.Warning("Operation cancelled")
-				return
+			confirmed, err := ui.Confirm("Are you sure you want to remove profile '"+profileName+"'", "--force")
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				ui.Warning("Operation cancelled\n")
+				return nil
 			}
 		}
 
 		// Remove profile
 		delete(cfg.Profiles, profileName)
-		if err := config.SaveConfig(cfg, configFile); err != nil {
-			ui.Error("Error saving configuration: %v", err)
-			return
+		if err := saveConfig(cfg, configFile); err != nil {
+			return err
 		}
 
-		ui.Success("Profile '%s' removed successfully", profileName)
+		ui.Success("Profile '%s' removed successfully\n", profileName)
+		return nil
 	},
 }
 
@@ -273,6 +379,7 @@ func init() {
 	profileCmd.AddCommand(profileListCmd)
 	profileCmd.AddCommand(profileAddCmd)
 	profileCmd.AddCommand(profileRemoveCmd)
+	profileCmd.AddCommand(profileShowCmd)
 
 	// Global flags for profile commands
 	profileCmd.PersistentFlags().StringP("config", "c", "", "Path to config file (default is $HOME/.gclone/config.yml)")
@@ -283,6 +390,18 @@ func init() {
 	profileAddCmd.Flags().StringP("git-username", "u", "", "Git username to configure for this profile")
 	profileAddCmd.Flags().StringP("git-email", "e", "", "Git email to configure for this profile")
 	profileAddCmd.Flags().StringArrayP("url-pattern", "p", []string{}, "URL patterns to automatically match this profile (can be specified multiple times)")
+	profileAddCmd.Flags().StringArray("git-config", []string{}, "Additional git config as key=value, such as commit.gpgsign=true (can be specified multiple times)")
+	profileAddCmd.Flags().BoolP("force", "f", false, "Overwrite an existing profile without confirmation")
+	profileAddCmd.Flags().String("config-mode", "", "How git configs reach repositories: copy (default) or include")
+	profileAddCmd.Flags().StringP("identity-file", "i", "", "SSH key to use through core.sshCommand instead of an SSH host alias")
+	profileAddCmd.Flags().StringArray("ssh-option", []string{}, "Extra ssh -o option used with --identity-file (can be specified multiple times)")
+	profileAddCmd.Flags().String("identity-guard", "", "Install a hook checking the commit identity: pre-commit or hooks-path")
+	profileAddCmd.Flags().StringArray("allowed-email-domain", []string{}, "Email domain allowed for commits (can be specified multiple times)")
+	profileAddCmd.Flags().StringArray("extends", []string{}, "Profile to inherit settings from (can be specified multiple times)")
+	profileAddCmd.Flags().Bool("abstract", false, "Only use the profile as a base for other profiles")
+
+	// Flags for profile show command
+	profileShowCmd.Flags().BoolP("resolved", "r", false, "Show the profile merged with the profiles it extends")
 
 	// Flags for profile remove command
 	profileRemoveCmd.Flags().BoolP("force", "f", false, "Force removal without confirmation")
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user-cube/gclone/pkg/registry"
+	"github.com/user-cube/gclone/pkg/ui"
+)
+
+// reposCmd represents the repos command
+var reposCmd = &cobra.Command{
+	Use:   "repos",
+	Short: "Manage the registry of repositories cloned by gclone",
+	Long: `Manage the registry of repositories cloned by gclone.
+Every successful clone is recorded in ~/.gclone/repos.json with its path,
+original URL, profile, time and the gclone version that cloned it.`,
+}
+
+// reposListCmd represents the repos list command
+var reposListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List registered repositories",
+	Long:  `List the repositories recorded in the registry.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		if output != "text" && output != "json" {
+			return &usageError{err: fmt.Errorf("invalid --output %q (expected text or json)", output)}
+		}
+
+		reg, err := registry.Load("")
+		if err != nil {
+			return err
+		}
+
+		entries := reg.Repositories
+		if profileName, _ := cmd.Flags().GetString("profile"); profileName != "" {
+			entries = reg.ForProfile(profileName)
+		}
+
+		if output == "json" {
+			if entries == nil {
+				entries = []registry.Entry{}
+			}
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(entries)
+		}
+
+		if len(entries) == 0 {
+			ui.Warning("No repositories registered\n")
+			return nil
+		}
+
+		table := ui.NewTable([]ui.TableColumn{
+			{Header: "Path", Width: 45},
+			{Header: "Profile", Width: 12},
+			{Header: "Cloned", Width: 16},
+			{Header: "URL", Width: 45},
+		})
+		for _, entry := range entries {
+			table.AddRow(entry.Path, entry.Profile, entry.ClonedAt.Format("2006-01-02 15:04"), entry.URL)
+		}
+		table.Print()
+
+		return nil
+	},
+}
+
+// reposShowCmd represents the repos show command
+var reposShowCmd = &cobra.Command{
+	Use:   "show [path]",
+	Short: "Show the registry entry of a repository",
+	Long:  `Show the registry entry of a repository (the current directory by default).`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := registryPath(args)
+		if err != nil {
+			return err
+		}
+
+		reg, err := registry.Load("")
+		if err != nil {
+			return err
+		}
+
+		entry, found := reg.Find(path)
+		if !found {
+			return fmt.Errorf("%s is not in the registry", path)
+		}
+
+		ui.Section("Repository " + ui.Highlight(entry.Path))
+		ui.PrintKeyValue("URL", entry.URL)
+		ui.PrintKeyValue("Profile", entry.Profile)
+		ui.PrintKeyValue("Cloned", entry.ClonedAt.Format("2006-01-02 15:04:05"))
+		ui.PrintKeyValue("gclone version", entry.GcloneVersion)
+		if _, err := os.Stat(entry.Path); os.IsNotExist(err) {
+			ui.Warning("  The repository no longer exists on disk\n")
+		}
+
+		return nil
+	},
+}
+
+// reposForgetCmd represents the repos forget command
+var reposForgetCmd = &cobra.Command{
+	Use:   "forget [path]",
+	Short: "Remove a repository from the registry",
+	Long: `Remove a repository from the registry (the current directory by default).
+The repository itself is left untouched. With --missing, every entry whose
+directory no longer exists is removed instead.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		missing, _ := cmd.Flags().GetBool("missing")
+
+		var path string
+		if !missing {
+			var err error
+			path, err = registryPath(args)
+			if err != nil {
+				return err
+			}
+		}
+
+		var forgotten []string
+		err := registry.Update("", func(reg *registry.Registry) error {
+			if !missing {
+				if !reg.Remove(path) {
+					return fmt.Errorf("%s is not in the registry", path)
+				}
+				forgotten = append(forgotten, path)
+				return nil
+			}
+
+			for _, entry := range append([]registry.Entry(nil), reg.Repositories...) {
+				if _, err := os.Stat(entry.Path); os.IsNotExist(err) {
+					reg.Remove(entry.Path)
+					forgotten = append(forgotten, entry.Path)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, path := range forgotten {
+			ui.Success("Forgot %s\n", path)
+		}
+		if len(forgotten) == 0 {
+			ui.Info("Nothing to forget\n")
+		}
+
+		return nil
+	},
+}
+
+// recordRepository adds a repository adopted by gclone to the registry.
+// Failing to do so is reported but does not fail the command.
+func recordRepository(path, url, profileName string) {
+	err := registry.Update("", func(reg *registry.Registry) error {
+		reg.Add(registry.Entry{
+			Path:          path,
+			URL:           url,
+			Profile:       profileName,
+			ClonedAt:      time.Now(),
+			GcloneVersion: Version,
+		})
+		return nil
+	})
+	if err != nil {
+		ui.Warning("Could not record repository in the registry: %v\n", err)
+	}
+}
+
+// registryPath resolves the repository path argument to the absolute path used as registry key
+func registryPath(args []string) (string, error) {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("error resolving %s: %w", path, err)
+	}
+	return absPath, nil
+}
+
+func init() {
+	rootCmd.AddCommand(reposCmd)
+	reposCmd.AddCommand(reposListCmd)
+	reposCmd.AddCommand(reposShowCmd)
+	reposCmd.AddCommand(reposForgetCmd)
+
+	reposListCmd.Flags().StringP("profile", "p", "", "Only list repositories cloned with this profile")
+	reposListCmd.Flags().StringP("output", "o", "text", "Output format (text, json)")
+	reposForgetCmd.Flags().Bool("missing", false, "Forget every repository that no longer exists on disk")
+}
@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"errors"
 	"os"
 
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 	"github.com/user-cube/gclone/pkg/ui"
 )
@@ -15,14 +17,39 @@ var rootCmd = &cobra.Command{
 clones with multiple profiles. It allows you to define different SSH hosts 
 and Git configurations for different Git accounts (e.g., personal, work), 
 and automatically applies the appropriate settings when cloning repositories.`,
+	// Unknown commands go through Args, so markUsageErrors reports them with ExitUsage
+	Args: unknownCommand,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// Never block on a prompt when there is nobody to answer it
+		nonInteractive, _ := cmd.Flags().GetBool("non-interactive")
+		if nonInteractive || !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+			ui.SetInteractive(false)
+		}
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		ui.Error("Error: %v", err)
-		os.Exit(1)
+	markUsageErrors(rootCmd)
+
+	if cmd, err := rootCmd.ExecuteC(); err != nil {
+		// A command run by gclone exec has already reported its own failure
+		var commandErr *commandExitError
+		if errors.As(err, &commandErr) && !commandErr.killed() {
+			os.Exit(commandErr.code)
+		}
+
+		ui.Error("Error: %v\n", err)
+
+		code := exitCode(err)
+		if code == ExitUsage {
+			ui.Normal("Run '%s --help' for usage.\n", cmd.CommandPath())
+		}
+		os.Exit(code)
 	}
 }
 
@@ -32,4 +59,12 @@ func init() {
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.gclone/config.yml)")
+	// Errors are printed by Execute, which also picks the exit code
+	rootCmd.SilenceErrors = true
+	rootCmd.SilenceUsage = true
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &usageError{err: err}
+	})
+
+	rootCmd.PersistentFlags().Bool("non-interactive", false, "Fail instead of prompting for input (default when stdin is not a terminal)")
 }
@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/git"
+	"github.com/user-cube/gclone/pkg/ui"
+)
+
+// configSubscribeCmd represents the config subscribe command
+var configSubscribeCmd = &cobra.Command{
+	Use:   "subscribe <repo-url>",
+	Short: "Use shared profiles from a git repository",
+	Long: `Clone a git repository of shared profiles into ~/.gclone/shared/<name> and
+merge its .yml files into the configuration, once they load and validate.
+Shared files are read-only layers between /etc/gclone/config.yml and your own
+files, so your own settings win.
+Run 'gclone config update' to pull later changes.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		if name == "" {
+			name = strings.TrimSuffix(path.Base(strings.TrimRight(args[0], "/")), ".git")
+		}
+		if name == "" || name == "." || strings.ContainsAny(name, `/\:`) {
+			return &usageError{err: fmt.Errorf("cannot derive a name from %s, use --name", args[0])}
+		}
+
+		subscriptions, err := config.LoadSubscriptions()
+		if err != nil {
+			return err
+		}
+		for _, s := range subscriptions {
+			if s.Name == name {
+				return &usageError{err: fmt.Errorf("already subscribed to '%s' (%s), use --name to pick another name", name, s.URL)}
+			}
+		}
+
+		subPath, _ := cmd.Flags().GetString("path")
+		subscription := config.Subscription{Name: name, URL: args[0], Path: subPath}
+		if _, err := os.Stat(subscription.Dir()); err == nil {
+			return fmt.Errorf("%s already exists, remove it first", subscription.Dir())
+		}
+
+		if err := git.CloneShared(&subscription); err != nil {
+			return err
+		}
+		// A repository with broken profiles would fail every command once merged
+		if err := subscription.Check(""); err != nil {
+			_ = os.RemoveAll(subscription.Dir())
+			return err
+		}
+		if err := config.SaveSubscriptions(append(subscriptions, subscription)); err != nil {
+			_ = os.RemoveAll(subscription.Dir())
+			return err
+		}
+
+		after, err := config.LoadFiles(subscription.Files())
+		if err != nil {
+			return err
+		}
+		printProfileChanges(config.DiffProfiles(nil, after.Profiles))
+
+		if err := regenerateProfileFiles(cmd); err != nil {
+			return err
+		}
+
+		ui.Success("Subscribed to '%s' with %d profiles\n", name, len(after.Profiles))
+		return nil
+	},
+}
+
+// configUpdateCmd represents the config update command
+var configUpdateCmd = &cobra.Command{
+	Use:   "update [name]",
+	Short: "Pull changes to shared profiles",
+	Long: `Fetch every subscribed repository of shared profiles (or only the named one)
+and show which settings changed. The fetched profiles are loaded and validated
+first; a repository whose profiles have errors stays at its previous commit.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		subscriptions, err := config.LoadSubscriptions()
+		if err != nil {
+			return err
+		}
+		if len(args) > 0 {
+			subscriptions, err = findSubscription(subscriptions, args[0])
+			if err != nil {
+				return err
+			}
+		}
+		if len(subscriptions) == 0 {
+			ui.Warning("No shared profiles subscribed, use 'gclone config subscribe <repo-url>'\n")
+			return nil
+		}
+
+		var failed []string
+		for _, s := range subscriptions {
+			ui.Section("Updating " + ui.Highlight(s.Name) + " from " + s.URL)
+
+			// A broken copy is what the update repairs, so compare against nothing
+			before, err := config.LoadFiles(s.Files())
+			if err != nil {
+				ui.Warning("%v\n", err)
+				before = &config.Config{}
+			}
+			if err := git.UpdateShared(&s); err != nil {
+				// The copy keeps its previous commit, the other subscriptions are still updated
+				ui.Error("%v\n", err)
+				ui.Warning("'%s' was not updated\n", s.Name)
+				failed = append(failed, s.Name)
+				continue
+			}
+			after, err := config.LoadFiles(s.Files())
+			if err != nil {
+				return err
+			}
+
+			changes := config.DiffProfiles(before.Profiles, after.Profiles)
+			if len(changes) == 0 {
+				ui.Info("No changes\n")
+				continue
+			}
+			printProfileChanges(changes)
+		}
+
+		if err := regenerateProfileFiles(cmd); err != nil {
+			return err
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("shared profiles not updated: %s", strings.Join(failed, ", "))
+		}
+		return nil
+	},
+}
+
+// configUnsubscribeCmd represents the config unsubscribe command
+var configUnsubscribeCmd = &cobra.Command{
+	Use:   "unsubscribe <name>",
+	Short: "Stop using shared profiles from a git repository",
+	Long:  `Stop merging a subscribed repository's profiles and remove its copy from ~/.gclone/shared.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		subscriptions, err := config.LoadSubscriptions()
+		if err != nil {
+			return err
+		}
+		if _, err := findSubscription(subscriptions, args[0]); err != nil {
+			return err
+		}
+
+		remaining := make([]config.Subscription, 0, len(subscriptions))
+		var removed config.Subscription
+		for _, s := range subscriptions {
+			if s.Name == args[0] {
+				removed = s
+			} else {
+				remaining = append(remaining, s)
+			}
+		}
+
+		if err := config.SaveSubscriptions(remaining); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(removed.Dir()); err != nil {
+			return fmt.Errorf("error removing %s: %w", removed.Dir(), err)
+		}
+
+		if err := regenerateProfileFiles(cmd); err != nil {
+			return err
+		}
+
+		ui.Success("Unsubscribed from '%s'\n", removed.Name)
+		return nil
+	},
+}
+
+// findSubscription returns the subscription with the given name as a list of one
+func findSubscription(subscriptions []config.Subscription, name string) ([]config.Subscription, error) {
+	for _, s := range subscriptions {
+		if s.Name == name {
+			return []config.Subscription{s}, nil
+		}
+	}
+	return nil, &usageError{err: fmt.Errorf("not subscribed to '%s'", name)}
+}
+
+// printProfileChanges prints the settings that changed, grouped by profile
+func printProfileChanges(changes []config.Change) {
+	colors := ui.NewColors()
+	added, removed, changed := colors.Green("+"), colors.Red("-"), colors.Yellow("~")
+
+	profile := ""
+	for _, c := range changes {
+		if c.Profile != profile {
+			profile = c.Profile
+			ui.Normal("  Profile %s:\n", ui.Highlight(profile))
+		}
+
+		if pattern, ok := strings.CutPrefix(c.Setting, "url_patterns."); ok {
+			marker := added
+			if c.New == "" {
+				marker = removed
+			}
+			ui.Normal("    %s url pattern %s\n", marker, pattern)
+			continue
+		}
+
+		switch {
+		case c.Old == "":
+			ui.Normal("    %s %s = %s\n", added, c.Setting, c.New)
+		case c.New == "":
+			ui.Normal("    %s %s (was %s)\n", removed, c.Setting, c.Old)
+		default:
+			ui.Normal("    %s %s = %s (was %s)\n", changed, c.Setting, c.New, c.Old)
+		}
+	}
+}
+
+// regenerateProfileFiles rewrites the profile git config files after shared
+// profiles changed, so repositories linking them pick up the change
+func regenerateProfileFiles(cmd *cobra.Command) error {
+	configFile, _ := cmd.Flags().GetString("config")
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+	return writeProfileFiles(cfg)
+}
+
+// writeProfileFiles regenerates the profile git config files, warning about
+// the profiles whose files were left as they were because their ${...}
+// references could not be expanded
+func writeProfileFiles(cfg *config.Config) error {
+	for _, name := range cfg.ProfileNames() {
+		if err := cfg.ProfileError(name); err != nil {
+			ui.Warning("The git config file of profile '%s' was not updated: %v\n", name, err)
+		}
+	}
+	if err := git.WriteProfileConfigFiles(cfg); err != nil {
+		return fmt.Errorf("error generating profile git config files: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	configCmd.AddCommand(configSubscribeCmd)
+	configCmd.AddCommand(configUpdateCmd)
+	configCmd.AddCommand(configUnsubscribeCmd)
+
+	for _, c := range []*cobra.Command{configSubscribeCmd, configUpdateCmd, configUnsubscribeCmd} {
+		c.Flags().StringP("config", "c", "", "Path to config file (default is $HOME/.gclone/config.yml)")
+	}
+	configSubscribeCmd.Flags().StringP("name", "n", "", "Name of the subscription (default is the repository name)")
+	configSubscribeCmd.Flags().StringP("path", "p", "", "Directory of the repository holding the profile files (default is the top)")
+}
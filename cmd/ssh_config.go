@@ -21,17 +21,18 @@ This command helps you set up the necessary SSH configuration in ~/.gclone/ssh_c
 that matches your gclone profile settings, and adds an Include directive to your 
 ~/.ssh/config file if needed.`,
 	Args: cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		configFile, _ := cmd.Flags().GetString("config")
 		cfg, err := config.LoadConfig(configFile)
 		if err != nil {
-			ui.Error("Error loading configuration: %v", err)
-			return
+			return fmt.Errorf("error loading configuration: %w", err)
 		}
 
 		if len(cfg.Profiles) == 0 {
-			ui.Warning("No profiles found. Run 'gclone init' to create default profiles.")
-			return
+			return &config.ConfigError{
+				Path: configFile,
+				Err:  fmt.Errorf("no profiles found in configuration, run 'gclone init' to create default profiles"),
+			}
 		}
 
 		var profileName string
@@ -44,31 +45,26 @@ that matches your gclone profile settings, and adds an Include directive to your
 			}
 		} else {
 			// If multiple profiles exist, prompt for selection
-			profileNames := make([]string, 0, len(cfg.Profiles))
-			for name := range cfg.Profiles {
-				profileNames = append(profileNames, name)
-			}
-
-			selectedProfile, err := ui.SelectFromList("Select profile to configure SSH for", profileNames)
+			selectedProfile, err := ui.SelectFromList("Select profile to configure SSH for", cfg.ProfileNames(), "the profile argument")
 			if err != nil {
-				ui.Error("Error selecting profile: %v", err)
-				return
+				return fmt.Errorf("error selecting profile: %w", err)
 			}
 			profileName = selectedProfile
 		}
 
 		// Check if profile exists
-		profile, ok := cfg.Profiles[profileName]
-		if !ok {
-			ui.Error("Profile '%s' not found", profileName)
-			return
+		profile, err := cfg.GetProfile(profileName)
+		if err != nil {
+			return err
 		}
 
 		// Generate SSH config
 		sshHost := profile.SSHHost
 		if sshHost == "" {
-			ui.Error("Profile '%s' does not have an SSH host configured", profileName)
-			return
+			return &config.ConfigError{
+				Path: configFile,
+				Err:  fmt.Errorf("profile '%s' does not have an SSH host configured", profileName),
+			}
 		}
 
 		identityFile, _ := cmd.Flags().GetString("identity-file")
@@ -76,10 +72,9 @@ that matches your gclone profile settings, and adds an Include directive to your
 			// Suggest a default identity file name
 			defaultIdentityFile := fmt.Sprintf("~/.ssh/%s", strings.Replace(sshHost, "github.com-", "github_", 1))
 			var err error
-			identityFile, err = ui.PromptInput("SSH identity file path", defaultIdentityFile, nil)
+			identityFile, err = ui.PromptInput("SSH identity file path", defaultIdentityFile, nil, "--identity-file")
 			if err != nil {
-				ui.Error("Error getting identity file: %v", err)
-				return
+				return fmt.Errorf("error getting identity file: %w", err)
 			}
 		}
 
@@ -94,8 +89,7 @@ Host %s
 		// Get SSH config path
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
-			ui.Error("Error getting home directory: %v", err)
-			return
+			return fmt.Errorf("error getting home directory: %w", err)
 		}
 
 		// Create the gclone SSH config file
@@ -106,21 +100,18 @@ Host %s
 
 		if dryRun {
 			ui.Section("SSH Configuration (Dry Run)")
-			ui.Info("The following configuration would be added to %s:", gcloneSshConfigPath)
-			fmt.Println()
+			ui.Info("The following configuration would be added to %s:\n\n", gcloneSshConfigPath)
 			ui.Normal("%s\n", sshConfig)
 
 			includeDirective := "Include ~/.gclone/ssh_config"
-			ui.Info("And the following include directive would be added to %s (if not already present):", mainSshConfigPath)
-			fmt.Println()
+			ui.Info("And the following include directive would be added to %s (if not already present):\n\n", mainSshConfigPath)
 			ui.Normal("%s\n", includeDirective)
-			return
+			return nil
 		}
 
 		// Ensure gclone directory exists
 		if err := os.MkdirAll(gcloneDir, 0755); err != nil {
-			ui.Error("Error creating gclone directory: %v", err)
-			return
+			return fmt.Errorf("error creating gclone directory: %w", err)
 		}
 
 		// Check if gclone SSH config file exists
@@ -133,20 +124,25 @@ Host %s
 			// Read existing gclone SSH config
 			content, err := os.ReadFile(gcloneSshConfigPath)
 			if err != nil {
-				ui.Error("Error reading gclone SSH config: %v", err)
-				return
+				return fmt.Errorf("error reading gclone SSH config: %w", err)
 			}
 			existingContent = string(content)
 
 			// Check if host already exists
 			hostPattern := fmt.Sprintf("Host %s", sshHost)
 			if strings.Contains(existingContent, hostPattern) {
-				ui.Warning("SSH configuration for '%s' already exists in %s", sshHost, gcloneSshConfigPath)
+				ui.Warning("SSH configuration for '%s' already exists in %s\n", sshHost, gcloneSshConfigPath)
 
-				confirmed, err := ui.Confirm("Do you want to update it")
-				if err != nil || !confirmed {
-					ui.Warning("Operation cancelled")
-					return
+				force, _ := cmd.Flags().GetBool("force")
+				if !force {
+					confirmed, err := ui.Confirm("Do you want to update it", "--force")
+					if err != nil {
+						return err
+					}
+					if !confirmed {
+						ui.Warning("Operation cancelled\n")
+						return nil
+					}
 				}
 
 				// Remove existing configuration for this host
@@ -188,39 +184,41 @@ Host %s
 
 		// Write the gclone SSH config file
 		if err := os.WriteFile(gcloneSshConfigPath, []byte(newContent), 0644); err != nil {
-			ui.Error("Error writing gclone SSH config: %v", err)
-			return
+			return fmt.Errorf("error writing gclone SSH config: %w", err)
 		}
 
 		// Now ensure the main SSH config includes our gclone SSH config
-		ensureIncludeDirective(mainSshConfigPath, "~/.gclone/ssh_config")
+		if err := ensureIncludeDirective(mainSshConfigPath, "~/.gclone/ssh_config"); err != nil {
+			return err
+		}
 
 		action := "created"
 		if gcloneSshConfigExists {
 			action = "updated"
 		}
 
-		ui.Success("SSH configuration %s at %s", action, gcloneSshConfigPath)
-		ui.Info("Configuration for '%s' added:", sshHost)
+		ui.Success("SSH configuration %s at %s\n", action, gcloneSshConfigPath)
+		ui.Info("Configuration for '%s' added:\n", sshHost)
 		ui.Normal("%s\n", sshConfig)
 
 		// Remind about creating the SSH key if it doesn't exist
 		identityFileExpanded := strings.Replace(identityFile, "~/", homeDir+"/", 1)
 		if _, err := os.Stat(identityFileExpanded); os.IsNotExist(err) {
-			ui.Warning("SSH key %s does not exist yet", identityFile)
-			ui.Info("You can create it with:")
+			ui.Warning("SSH key %s does not exist yet\n", identityFile)
+			ui.Info("You can create it with:\n")
 			ui.Normal("  ssh-keygen -t ed25519 -f %s -C \"your_email@example.com\"\n", identityFile)
 		}
+
+		return nil
 	},
 }
 
 // ensureIncludeDirective ensures that the specified SSH config file includes the given path
-func ensureIncludeDirective(sshConfigPath, includePath string) {
+func ensureIncludeDirective(sshConfigPath, includePath string) error {
 	// Ensure SSH directory exists
 	sshDir := filepath.Dir(sshConfigPath)
 	if err := os.MkdirAll(sshDir, 0700); err != nil {
-		ui.Error("Error creating SSH directory: %v", err)
-		return
+		return fmt.Errorf("error creating SSH directory: %w", err)
 	}
 
 	includeDirective := fmt.Sprintf("Include %s", includePath)
@@ -230,14 +228,13 @@ func ensureIncludeDirective(sshConfigPath, includePath string) {
 		// Read existing SSH config
 		content, err := os.ReadFile(sshConfigPath)
 		if err != nil {
-			ui.Error("Error reading SSH config: %v", err)
-			return
+			return fmt.Errorf("error reading SSH config: %w", err)
 		}
 
 		// Check if include directive already exists
 		if strings.Contains(string(content), includeDirective) {
 			// Include directive already exists, nothing to do
-			return
+			return nil
 		}
 
 		// Add include directive at the top of the file with other includes
@@ -274,20 +271,20 @@ func ensureIncludeDirective(sshConfigPath, includePath string) {
 
 		// Write back the updated content
 		if err := os.WriteFile(sshConfigPath, []byte(strings.Join(newLines, "\n")), 0644); err != nil {
-			ui.Error("Error updating SSH config: %v", err)
-			return
+			return fmt.Errorf("error updating SSH config: %w", err)
 		}
 
-		ui.Success("Added include directive to %s", sshConfigPath)
+		ui.Success("Added include directive to %s\n", sshConfigPath)
 	} else {
 		// Create new SSH config file with include directive
 		if err := os.WriteFile(sshConfigPath, []byte(includeDirective+"\n"), 0644); err != nil {
-			ui.Error("Error creating SSH config: %v", err)
-			return
+			return fmt.Errorf("error creating SSH config: %w", err)
 		}
 
-		ui.Success("Created SSH config file at %s with include directive", sshConfigPath)
+		ui.Success("Created SSH config file at %s with include directive\n", sshConfigPath)
 	}
+
+	return nil
 }
 
 func init() {
@@ -295,4 +292,5 @@ func init() {
 	sshConfigCmd.Flags().StringP("config", "c", "", "Path to config file (default is $HOME/.gclone/config.yml)")
 	sshConfigCmd.Flags().StringP("identity-file", "i", "", "Path to SSH identity file (default is ~/.ssh/github_<profile>)")
 	sshConfigCmd.Flags().BoolP("dry-run", "d", false, "Print the configuration without writing to file")
+	sshConfigCmd.Flags().BoolP("force", "f", false, "Update an existing SSH configuration without confirmation")
 }
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/git"
+	"github.com/user-cube/gclone/pkg/registry"
+	"github.com/user-cube/gclone/pkg/sshconfig"
+	"github.com/user-cube/gclone/pkg/ui"
+)
+
+// switchCmd represents the switch command
+var switchCmd = &cobra.Command{
+	Use:   "switch <profile> [path]",
+	Short: "Move an existing repository to a different profile",
+	Long: `Move a repository (the current directory by default) to a different profile.
+Remotes are rewritten from the old profile's SSH host alias to the new one,
+the old profile's local git configs are removed and the new ones applied.
+The switch is recorded in the repository and the registry.
+
+The old alias is mapped back to the real host through ~/.ssh/config and
+~/.gclone/ssh_config. When that is not possible the switch is refused, since
+the remote could otherwise end up pointing at a different server.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profileName := args[0]
+		path := "."
+		if len(args) > 1 {
+			path = args[1]
+		}
+
+		repoPath, err := git.FindRepositoryRoot(path)
+		if err != nil {
+			return err
+		}
+
+		configFile, _ := cmd.Flags().GetString("config")
+		cfg, err := config.LoadConfig(configFile)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+
+		profile, err := cfg.GetProfile(profileName)
+		if err != nil {
+			return err
+		}
+
+		var from *config.Profile
+		fromName := currentRepositoryProfile(cfg, repoPath)
+		if oldProfile, err := cfg.GetProfile(fromName); err == nil {
+			from = &oldProfile
+		}
+
+		hosts, err := sshconfig.Load(sshconfig.DefaultFiles()...)
+		if err != nil {
+			return err
+		}
+
+		plan, err := git.PlanSwitch(repoPath, from, profileName, &profile, cfg.Profiles, hosts)
+		if err != nil {
+			return err
+		}
+
+		details := map[string]string{"Repository": repoPath}
+		if fromName != "" {
+			details["From"] = fromName
+		}
+		ui.OperationInfo("Switching", profileName, details)
+		printApplyPlan(cfg, plan)
+
+		if plan.IsEmpty() && !plan.RecordOutdated {
+			ui.Success("Repository already follows profile '%s'\n", profileName)
+			return nil
+		}
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if dryRun {
+			return nil
+		}
+
+		yes, _ := cmd.Flags().GetBool("yes")
+		if !yes {
+			confirmed, err := ui.Confirm("Switch to profile '"+profileName+"'", "--yes")
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				ui.Warning("Operation cancelled\n")
+				return nil
+			}
+		}
+
+		plan.ConfigFile = configFile
+		if err := plan.Execute(); err != nil {
+			return err
+		}
+
+		err = registry.Update("", func(reg *registry.Registry) error {
+			if entry, found := reg.Find(repoPath); found {
+				entry.Profile = profileName
+				return nil
+			}
+			originURL, _ := git.GetRemoteURL(repoPath, "origin")
+			reg.Add(registry.Entry{
+				Path:          repoPath,
+				URL:           originURL,
+				Profile:       profileName,
+				ClonedAt:      time.Now(),
+				GcloneVersion: Version,
+			})
+			return nil
+		})
+		if err != nil {
+			ui.Warning("Could not record repository in the registry: %v\n", err)
+		}
+
+		ui.OperationSuccess(fmt.Sprintf("Repository now follows profile '%s'", profileName))
+		return nil
+	},
+}
+
+// currentRepositoryProfile returns the profile a repository follows: the one
+// gclone recorded in it, then the registry entry, then the origin remote.
+// It is empty when none is known.
+func currentRepositoryProfile(cfg *config.Config, repoPath string) string {
+	if profileName, _, err := git.ReadProfileRecord(repoPath); err == nil && profileName != "" {
+		return profileName
+	}
+	if reg, err := registry.Load(""); err == nil {
+		if entry, found := reg.Find(repoPath); found && entry.Profile != "" {
+			return entry.Profile
+		}
+	}
+	if originURL, err := git.GetRemoteURL(repoPath, "origin"); err == nil {
+		if profileName, found := git.DetectProfileForURL(originURL, cfg.Profiles); found {
+			return profileName
+		}
+	}
+	return ""
+}
+
+func init() {
+	rootCmd.AddCommand(switchCmd)
+	switchCmd.Flags().StringP("config", "c", "", "Path to config file (default is $HOME/.gclone/config.yml)")
+	switchCmd.Flags().BoolP("yes", "y", false, "Switch without confirmation")
+	switchCmd.Flags().BoolP("dry-run", "d", false, "Show the changes without applying them")
+}
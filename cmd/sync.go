@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/git"
+	"github.com/user-cube/gclone/pkg/registry"
+	"github.com/user-cube/gclone/pkg/ui"
+)
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Re-apply profiles to previously cloned repositories",
+	Long: `Re-apply the current profile settings to repositories cloned or adopted earlier.
+Repositories are found in the registry and by scanning the 'roots' directories
+of each profile (and any --root given). Git configs that changed in the profile
+are updated, keys that were removed from the profile are unset, and remotes are
+rewritten to the profile's SSH host. Changes are shown per repository and made
+after confirmation.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configFile, _ := cmd.Flags().GetString("config")
+		cfg, err := config.LoadConfig(configFile)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+
+		onlyProfile, _ := cmd.Flags().GetString("profile")
+		if onlyProfile != "" {
+			if _, err := cfg.GetProfile(onlyProfile); err != nil {
+				return err
+			}
+		}
+
+		// The profiles may have been edited by hand since the files were generated
+		if err := writeProfileFiles(cfg); err != nil {
+			return err
+		}
+
+		roots, _ := cmd.Flags().GetStringArray("root")
+		repos, err := findSyncRepositories(cfg, roots)
+		if err != nil {
+			return err
+		}
+
+		var plans []*git.ApplyPlan
+		changed := 0
+		for _, repo := range repos {
+			profileName := detectSyncProfile(cfg, repo)
+			if profileName == "" || (onlyProfile != "" && profileName != onlyProfile) {
+				continue
+			}
+
+			profile, err := cfg.GetProfile(profileName)
+			if err != nil {
+				ui.Warning("Skipping %s: %v\n", repo.path, err)
+				continue
+			}
+
+			plan, err := git.PlanApply(repo.path, profileName, &profile)
+			if err != nil {
+				return fmt.Errorf("error checking %s: %w", repo.path, err)
+			}
+			plan.ConfigFile = configFile
+			plans = append(plans, plan)
+
+			if plan.IsEmpty() {
+				continue
+			}
+			changed++
+			ui.Section(fmt.Sprintf("%s (%s)", repo.path, profileName))
+			printApplyPlan(cfg, plan)
+		}
+
+		if len(plans) == 0 {
+			ui.Warning("No repositories found\n")
+			return nil
+		}
+		ui.Info("%d repositories checked, %d need changes\n", len(plans), changed)
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if dryRun {
+			return nil
+		}
+
+		yes, _ := cmd.Flags().GetBool("yes")
+		if changed > 0 && !yes {
+			confirmed, err := ui.Confirm(fmt.Sprintf("Update %d repositories", changed), "--yes")
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				ui.Warning("Operation cancelled\n")
+				return nil
+			}
+		}
+
+		for _, plan := range plans {
+			if plan.IsEmpty() && !plan.RecordOutdated {
+				continue
+			}
+			if err := plan.Execute(); err != nil {
+				return fmt.Errorf("error updating %s: %w", plan.RepoPath, err)
+			}
+		}
+
+		if changed > 0 {
+			ui.Success("%d repositories updated\n", changed)
+		} else {
+			ui.Success("All repositories follow their profile\n")
+		}
+		return nil
+	},
+}
+
+// syncRepository is a repository found by gclone sync, with the profile the registry knows for it
+type syncRepository struct {
+	path    string
+	profile string
+}
+
+// findSyncRepositories collects repositories from the registry and from the
+// profile roots and extra roots, without duplicates
+func findSyncRepositories(cfg *config.Config, extraRoots []string) ([]syncRepository, error) {
+	seen := make(map[string]int)
+	var repos []syncRepository
+	add := func(path, profileName string) {
+		if i, found := seen[path]; found {
+			if repos[i].profile == "" {
+				repos[i].profile = profileName
+			}
+			return
+		}
+		seen[path] = len(repos)
+		repos = append(repos, syncRepository{path: path, profile: profileName})
+	}
+
+	reg, err := registry.Load("")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range reg.Repositories {
+		if _, err := os.Stat(entry.Path); err != nil {
+			ui.Warning("Skipping %s: no longer exists (run 'gclone repos forget --missing')\n", entry.Path)
+			continue
+		}
+		add(entry.Path, entry.Profile)
+	}
+
+	roots := append([]string(nil), extraRoots...)
+	for _, name := range cfg.ProfileNames() {
+		roots = append(roots, cfg.Profiles[name].Roots...)
+	}
+	for _, root := range roots {
+		root := config.ExpandHome(root)
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			ui.Warning("Skipping root %s: directory does not exist\n", root)
+			continue
+		}
+		found, err := git.FindRepositories(root)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range found {
+			absPath, err := filepath.Abs(path)
+			if err != nil {
+				return nil, fmt.Errorf("error resolving %s: %w", path, err)
+			}
+			add(absPath, "")
+		}
+	}
+
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].path < repos[j].path
+	})
+	return repos, nil
+}
+
+// detectSyncProfile works out the profile of a repository: the profile gclone
+// recorded in the repository itself, then the registry, then the origin remote
+func detectSyncProfile(cfg *config.Config, repo syncRepository) string {
+	if profileName, _, err := git.ReadProfileRecord(repo.path); err == nil && profileName != "" {
+		return profileName
+	}
+	if repo.profile != "" {
+		return repo.profile
+	}
+	if originURL, err := git.GetRemoteURL(repo.path, "origin"); err == nil {
+		if profileName, found := git.DetectProfileForURL(originURL, cfg.Profiles); found {
+			return profileName
+		}
+	}
+	return ""
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().StringP("config", "c", "", "Path to config file (default is $HOME/.gclone/config.yml)")
+	syncCmd.Flags().StringP("profile", "p", "", "Only sync repositories of this profile")
+	syncCmd.Flags().StringArray("root", nil, "Additional directory to scan for repositories (repeatable)")
+	syncCmd.Flags().BoolP("yes", "y", false, "Apply the changes without confirmation")
+	syncCmd.Flags().BoolP("dry-run", "d", false, "Show the changes without applying them")
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user-cube/gclone/pkg/fileutil"
+)
+
+// MaxBackups is the number of backups kept of each config file
+const MaxBackups = 10
+
+// backupTimeFormat names backups so that name order is age order
+const backupTimeFormat = "20060102-150405.000000"
+
+// Backup is a copy of a config file taken before gclone replaced it
+type Backup struct {
+	Path string
+	Time time.Time
+}
+
+// BackupDir returns the directory holding the backups of config files
+func BackupDir() string {
+	return filepath.Join(DefaultConfigDir(), "backups")
+}
+
+// LockConfig takes the advisory lock of a config file (the default one when
+// empty). Hold it from loading the file until saving it, so concurrent gclone
+// processes never lose each other's changes.
+func LockConfig(configFile string) (*fileutil.Lock, error) {
+	if configFile == "" {
+		configFile = DefaultConfigFile()
+	}
+	lock, err := fileutil.LockFile(configFile)
+	if err != nil {
+		return nil, &ConfigError{Path: configFile, Err: err}
+	}
+	return lock, nil
+}
+
+// TryLockConfig takes the lock of LockConfig without waiting; ok is false
+// when another process holds it
+func TryLockConfig(configFile string) (lock *fileutil.Lock, ok bool, err error) {
+	if configFile == "" {
+		configFile = DefaultConfigFile()
+	}
+	lock, ok, err = fileutil.TryLockFile(configFile)
+	if err != nil {
+		return nil, false, &ConfigError{Path: configFile, Err: err}
+	}
+	return lock, ok, nil
+}
+
+// WriteConfigFile replaces a config file with data atomically, keeping its
+// permissions. The previous content is backed up first unless it is unchanged.
+func WriteConfigFile(configFile string, data []byte) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(configFile); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	previous, err := os.ReadFile(configFile)
+	switch {
+	case err == nil && bytes.Equal(previous, data):
+		return nil
+	case err == nil:
+		if err := backupConfig(configFile, previous); err != nil {
+			return &ConfigError{Path: configFile, Err: fmt.Errorf("error backing up config file: %w", err)}
+		}
+	case !os.IsNotExist(err):
+		return &ConfigError{Path: configFile, Err: fmt.Errorf("error reading config file: %w", err)}
+	}
+
+	if err := fileutil.WriteFileAtomic(configFile, data, perm); err != nil {
+		return &ConfigError{Path: configFile, Err: fmt.Errorf("error writing config file: %w", err)}
+	}
+	return nil
+}
+
+// ListBackups returns the backups of a config file (the default one when
+// empty), newest first
+func ListBackups(configFile string) ([]Backup, error) {
+	if configFile == "" {
+		configFile = DefaultConfigFile()
+	}
+	prefix, suffix := backupPrefix(configFile), backupSuffix(configFile)
+
+	entries, err := os.ReadDir(BackupDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading backups: %w", err)
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, strings.TrimSuffix(stamp, suffix), time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{Path: filepath.Join(BackupDir(), entry.Name()), Time: t})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+	return backups, nil
+}
+
+// RestoreBackup replaces a config file (the default one when empty) with one
+// of its backups. The backup must load, and the current file is backed up
+// first so the restore can be undone.
+func RestoreBackup(configFile string, backup Backup) error {
+	if configFile == "" {
+		configFile = DefaultConfigFile()
+	}
+
+	data, err := os.ReadFile(backup.Path)
+	if err != nil {
+		return &ConfigError{Path: backup.Path, Err: fmt.Errorf("error reading backup: %w", err)}
+	}
+	if _, err := loadFile(backup.Path, false); err != nil {
+		return err
+	}
+
+	return WriteConfigFile(configFile, data)
+}
+
+// backupConfig stores the content of a config file in BackupDir and removes
+// its oldest backups beyond MaxBackups
+func backupConfig(configFile string, data []byte) error {
+	_, err := BackupFile(configFile, data)
+	return err
+}
+
+// BackupFile stores data as a backup of file in BackupDir, named like the
+// backups of config files, and removes its oldest backups beyond MaxBackups.
+// Other files gclone edits, such as ~/.gitconfig, are backed up this way too.
+// It returns the path of the backup.
+func BackupFile(file string, data []byte) (string, error) {
+	backup := filepath.Join(BackupDir(), backupPrefix(file)+time.Now().Format(backupTimeFormat)+backupSuffix(file))
+	if err := fileutil.WriteFileAtomic(backup, data, 0600); err != nil {
+		return "", err
+	}
+
+	backups, err := ListBackups(file)
+	if err != nil {
+		return "", err
+	}
+	for _, old := range backups[min(len(backups), MaxBackups):] {
+		if err := os.Remove(old.Path); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return backup, nil
+}
+
+// backupPrefix starts the names of the backups of a config file: its base
+// name, for reading, and a hash of its absolute path, so backups of files of
+// the same name passed with --config are kept apart from the default file's
+func backupPrefix(configFile string) string {
+	if abs, err := filepath.Abs(configFile); err == nil {
+		configFile = abs
+	}
+	sum := sha256.Sum256([]byte(configFile))
+	base := strings.TrimSuffix(filepath.Base(configFile), backupSuffix(configFile))
+	// Dotfiles such as .gitconfig get visible backups
+	base = strings.TrimPrefix(base, ".")
+	return fmt.Sprintf("%s-%x-", base, sum[:4])
+}
+
+// backupSuffix ends the names of the backups of a file: its extension, or
+// .bak for files without one such as .gitconfig
+func backupSuffix(file string) string {
+	base := filepath.Base(file)
+	if ext := filepath.Ext(base); ext != "" && ext != base {
+		return ext
+	}
+	return ".bak"
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackupsOfFilesWithTheSameName(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := filepath.Join(t.TempDir(), "config.yml")
+	b := filepath.Join(t.TempDir(), "config.yml")
+
+	for _, file := range []string{a, b} {
+		if err := os.WriteFile(file, []byte("version: 1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteConfigFile(file, []byte("version: 1\nprofiles: {}\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := WriteConfigFile(a, []byte("version: 1\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	backupsA, err := ListBackups(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupsB, err := ListBackups(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backupsA) != 2 || len(backupsB) != 1 {
+		t.Errorf("ListBackups() found %d and %d backups, want 2 and 1", len(backupsA), len(backupsB))
+	}
+}
+
+func TestBackupFileRotatesDotfileBackups(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	file := filepath.Join(t.TempDir(), ".gitconfig")
+
+	for i := 0; i < MaxBackups+2; i++ {
+		backup, err := BackupFile(file, []byte("[user]\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Dir(backup) != BackupDir() || !strings.HasPrefix(filepath.Base(backup), "gitconfig-") || filepath.Ext(backup) != ".bak" {
+			t.Fatalf("BackupFile() = %s, want a gitconfig-*.bak file in %s", backup, BackupDir())
+		}
+		// Backups are named by time, keep their names apart
+		time.Sleep(time.Millisecond)
+	}
+
+	backups, err := ListBackups(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != MaxBackups {
+		t.Errorf("ListBackups() found %d backups, want %d", len(backups), MaxBackups)
+	}
+}
+
+func TestMigrateFileUnderTheCommandLock(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	file := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(file, []byte("profiles: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Commands lock the file before loading it, the upgrade runs under that lock
+	lock, err := LockConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = lock.Unlock()
+	}()
+	if _, err := LoadConfigFile(file); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "version: 1\nprofiles: {}\n" {
+		t.Errorf("migrated file = %q, want the version added", data)
+	}
+	if backups, _ := ListBackups(file); len(backups) != 1 {
+		t.Errorf("ListBackups() found %d backups, want 1", len(backups))
+	}
+}
@@ -4,21 +4,112 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the main configuration structure
 type Config struct {
+	// Version is the schema version of the file, see SchemaVersion
+	Version  int                `yaml:"version"`
 	Profiles map[string]Profile `yaml:"profiles"`
+
+	// origins records the file each setting was read from, see Origin
+	origins map[originKey]string
+	// secrets records the settings read from secret files, see IsSecret
+	secrets map[originKey]bool
+	// unresolved records the profiles whose references could not be expanded, see ProfileError
+	unresolved map[string]error
 }
 
 // Profile represents a single profile configuration
 type Profile struct {
-	Name        string            `yaml:"name"`
-	SSHHost     string            `yaml:"ssh_host"`
-	URLPatterns []string          `yaml:"url_patterns"`
-	GitConfigs  map[string]string `yaml:"git_configs"`
+	Name        string            `yaml:"name,omitempty"`
+	SSHHost     string            `yaml:"ssh_host,omitempty"`
+	URLPatterns []string          `yaml:"url_patterns,omitempty"`
+	GitConfigs  map[string]string `yaml:"git_configs,omitempty"`
+	// Roots are directories scanned by gclone sync for repositories of this profile
+	Roots []string `yaml:"roots,omitempty"`
+	// ConfigMode selects how GitConfigs reach a repository, see ConfigModeCopy and ConfigModeInclude
+	ConfigMode string `yaml:"config_mode,omitempty"`
+	// IdentityFile is the SSH key used through core.sshCommand instead of an SSH host alias
+	IdentityFile string `yaml:"identity_file,omitempty"`
+	// SSHOptions are extra ssh -o options (such as Port=2222) used with IdentityFile
+	SSHOptions []string `yaml:"ssh_options,omitempty"`
+	// IdentityGuard installs a hook checking the commit identity, see GuardPreCommit and GuardHooksPath
+	IdentityGuard string `yaml:"identity_guard,omitempty"`
+	// AllowedEmailDomains restricts commit emails to these domains when set
+	AllowedEmailDomains []string `yaml:"allowed_email_domains,omitempty"`
+	// Extends names the profiles this one inherits settings from, see Config.Resolve
+	Extends ParentList `yaml:"extends,omitempty"`
+	// Abstract profiles only exist to be extended and cannot be used directly
+	Abstract bool `yaml:"abstract,omitempty"`
+}
+
+// Ways of applying a profile's git configs to a repository
+const (
+	// ConfigModeCopy sets every key in the repository's local git config (the default)
+	ConfigModeCopy = "copy"
+	// ConfigModeInclude points include.path at a file generated from the profile,
+	// so later profile edits take effect without touching the repository
+	ConfigModeInclude = "include"
+)
+
+// Ways of installing the identity guard hook
+const (
+	// GuardPreCommit installs a pre-commit hook in the repository
+	GuardPreCommit = "pre-commit"
+	// GuardHooksPath points core.hooksPath at hooks managed by gclone
+	GuardHooksPath = "hooks-path"
+)
+
+// profileNamePattern matches the names profiles can have. Names become file
+// names (see git.ProfileConfigFile), so they cannot hold path separators or
+// start with a dot.
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// ValidateProfileName checks that a profile name is usable
+func ValidateProfileName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name '%s', use letters, digits, '.', '_' and '-', not starting with '.'", name)
+	}
+	return nil
+}
+
+// UsesInclude reports whether the profile links its git configs through include.path
+func (p *Profile) UsesInclude() bool {
+	return p.ConfigMode == ConfigModeInclude
+}
+
+// GetProfile returns the profile with the given name. Abstract profiles cannot
+// be used, nor can profiles whose references could not be expanded.
+func (c *Config) GetProfile(name string) (Profile, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, &ProfileNotFoundError{Name: name}
+	}
+	if profile.Abstract {
+		return Profile{}, &AbstractProfileError{Name: name}
+	}
+	if err := c.ProfileError(name); err != nil {
+		return Profile{}, err
+	}
+	return profile, nil
+}
+
+// ProfileNames returns the names of all profiles that can be used, leaving
+// out abstract ones, in alphabetical order
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name, profile := range c.Profiles {
+		if !profile.Abstract {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
 }
 
 // DefaultConfigDir returns the default config directory path
@@ -30,28 +121,88 @@ func DefaultConfigDir() string {
 	return filepath.Join(home, ".gclone")
 }
 
-// DefaultConfigFile returns the default config file path
+// DefaultConfigFile returns the default config file path: ~/.gclone/config.yml,
+// or the XDG config file when only that one exists
 func DefaultConfigFile() string {
-	return filepath.Join(DefaultConfigDir(), "config.yml")
+	file := filepath.Join(DefaultConfigDir(), "config.yml")
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		if xdg := XDGConfigFile(); xdg != "" {
+			if _, err := os.Stat(xdg); err == nil {
+				return xdg
+			}
+		}
+	}
+	return file
 }
 
-// LoadConfig loads the configuration from the specified file
+// LoadConfig loads the configuration ready to be used: the specified file is
+// laid over the system, XDG and config.d files (see LayerFiles), profile
+// inheritance is resolved and ${...} references are expanded (see Interpolate)
 func LoadConfig(configFile string) (*Config, error) {
+	user, err := LoadConfigFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	config, err := MergeLayers(configFile, user)
+	if err != nil {
+		return nil, err
+	}
+	if configFile == "" {
+		configFile = DefaultConfigFile()
+	}
+	if err := config.Resolve(); err != nil {
+		return nil, &ConfigError{Path: configFile, Err: err}
+	}
+	config.interpolate(configFile)
+	return config, nil
+}
+
+// LoadConfigFile loads only the specified file as written, without other
+// layers or inheritance. Commands that change and save the configuration use
+// it so settings from elsewhere are not copied into the file.
+func LoadConfigFile(configFile string) (*Config, error) {
 	if configFile == "" {
 		configFile = DefaultConfigFile()
 	}
+	return loadFile(configFile, true)
+}
 
+// loadFile reads one config file. Older files are upgraded in place when
+// migrate is set, and in memory only otherwise.
+func loadFile(configFile string, migrate bool) (*Config, error) {
 	data, err := os.ReadFile(configFile)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return &Config{Profiles: make(map[string]Profile)}, nil
+			return &Config{Version: SchemaVersion, Profiles: make(map[string]Profile)}, nil
+		}
+		return nil, &ConfigError{Path: configFile, Err: fmt.Errorf("error reading config file: %w", err)}
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, &ConfigError{Path: configFile, Err: fmt.Errorf("error parsing config file: %w", err)}
+	}
+
+	// Older files are upgraded in place; newer ones are read as far as this
+	// gclone understands them but never written back (see SaveConfig)
+	version, err := documentVersion(&root)
+	if err != nil {
+		return nil, &ConfigError{Path: configFile, Err: err}
+	}
+	if version < SchemaVersion && documentMapping(&root) != nil {
+		if _, err := upgradeDocument(&root); err != nil {
+			return nil, &ConfigError{Path: configFile, Err: err}
+		}
+		if migrate {
+			migrateFile(configFile, data, &root, version)
 		}
-		return nil, fmt.Errorf("error reading config file: %w", err)
 	}
 
 	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("error parsing config file: %w", err)
+	if root.Kind != 0 {
+		if err := root.Decode(&config); err != nil {
+			return nil, &ConfigError{Path: configFile, Err: fmt.Errorf("error parsing config file: %w", err)}
+		}
 	}
 
 	// Initialize maps if they're nil
@@ -69,33 +220,41 @@ func LoadConfig(configFile string) (*Config, error) {
 	return &config, nil
 }
 
-// SaveConfig saves the configuration to the specified file
+// SaveConfig saves the configuration to the specified file atomically,
+// backing up the previous version (see WriteConfigFile). An existing file is
+// edited in place, keeping its comments, key order and anchors.
 func SaveConfig(config *Config, configFile string) error {
 	if configFile == "" {
 		configFile = DefaultConfigFile()
 	}
 
-	// Ensure the directory exists
-	configDir := filepath.Dir(configFile)
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return fmt.Errorf("error creating config directory: %w", err)
+	// Never downgrade a file written by a newer gclone, its extra settings would be lost
+	if config.Version > SchemaVersion {
+		return &ConfigError{Path: configFile, Err: &NewerSchemaError{Version: config.Version}}
 	}
-
-	data, err := yaml.Marshal(config)
-	if err != nil {
-		return fmt.Errorf("error encoding config: %w", err)
+	previous, _ := os.ReadFile(configFile)
+	if previous != nil {
+		var root yaml.Node
+		if yaml.Unmarshal(previous, &root) == nil {
+			if version, err := documentVersion(&root); err == nil && version > SchemaVersion {
+				return &ConfigError{Path: configFile, Err: &NewerSchemaError{Version: version}}
+			}
+		}
 	}
+	config.Version = SchemaVersion
 
-	if err := os.WriteFile(configFile, data, 0644); err != nil {
-		return fmt.Errorf("error writing config file: %w", err)
+	data, err := encodeConfig(config, previous, configFile)
+	if err != nil {
+		return &ConfigError{Path: configFile, Err: fmt.Errorf("error encoding config: %w", err)}
 	}
 
-	return nil
+	return WriteConfigFile(configFile, data)
 }
 
 // GetDefaultConfig returns a default configuration
 func GetDefaultConfig() *Config {
 	return &Config{
+		Version: SchemaVersion,
 		Profiles: map[string]Profile{
 			"personal": {
 				Name:    "Personal",
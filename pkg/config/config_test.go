@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateProfileName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"work", true},
+		{"client-a.prod_2", true},
+		{"", false},
+		{".hidden", false},
+		{"..", false},
+		{"../escape", false},
+		{`a\b`, false},
+		{"with space", false},
+		{"ünicode", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateProfileName(tt.name); (err == nil) != tt.valid {
+			t.Errorf("ValidateProfileName(%q) = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestLoadConfigSkipsInvalidProfileNames(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	file := filepath.Join(t.TempDir(), "config.yml")
+	input := `version: 1
+profiles:
+  work:
+    name: Work
+    ssh_host: alias-work
+  ../escape:
+    name: Escape
+    ssh_host: alias-escape
+`
+	if err := os.WriteFile(file, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(file)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if _, ok := cfg.Profiles["../escape"]; ok {
+		t.Error("LoadConfig() kept the profile with an invalid name")
+	}
+	if _, err := cfg.GetProfile("work"); err != nil {
+		t.Errorf("GetProfile(work) error = %v", err)
+	}
+
+	diagnostics, err := Validate(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !HasErrors(diagnostics) {
+		t.Errorf("Validate() = %v, want an error for the invalid name", diagnostics)
+	}
+}
@@ -0,0 +1,35 @@
+package config
+
+import "fmt"
+
+// ProfileNotFoundError is returned when a profile is not defined in the configuration
+type ProfileNotFoundError struct {
+	Name string
+}
+
+func (e *ProfileNotFoundError) Error() string {
+	return fmt.Sprintf("profile '%s' not found", e.Name)
+}
+
+// AbstractProfileError is returned when an abstract profile is used directly
+type AbstractProfileError struct {
+	Name string
+}
+
+func (e *AbstractProfileError) Error() string {
+	return fmt.Sprintf("profile '%s' is abstract and can only be extended by other profiles", e.Name)
+}
+
+// ConfigError is returned when the configuration file cannot be read, parsed or written
+type ConfigError struct {
+	Path string
+	Err  error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParentList names the profiles a profile extends. It is written as a single
+// name or a list of names.
+type ParentList []string
+
+// UnmarshalYAML accepts a single name as well as a list
+func (p *ParentList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*p = ParentList{node.Value}
+		return nil
+	}
+	var names []string
+	if err := node.Decode(&names); err != nil {
+		return fmt.Errorf("extends must be a profile name or a list of profile names")
+	}
+	*p = names
+	return nil
+}
+
+// MarshalYAML writes a single parent as a plain name
+func (p ParentList) MarshalYAML() (interface{}, error) {
+	if len(p) == 1 {
+		return p[0], nil
+	}
+	return []string(p), nil
+}
+
+// InheritanceError is returned when profiles extend unknown profiles or each other in a cycle
+type InheritanceError struct {
+	Profile string
+	Err     error
+}
+
+func (e *InheritanceError) Error() string {
+	return fmt.Sprintf("profile '%s': %v", e.Profile, e.Err)
+}
+
+func (e *InheritanceError) Unwrap() error {
+	return e.Err
+}
+
+// Resolve replaces every profile with the result of merging it over the
+// profiles it extends. Parents are applied in order, so later parents win
+// over earlier ones and the profile itself wins over all of them:
+// git_configs are merged key by key, url_patterns are combined, and other
+// settings are inherited when the profile leaves them empty. Abstract is
+// never inherited.
+func (c *Config) Resolve() error {
+	r := newResolver(c.Profiles)
+	r.rawOrigins = c.origins
+	resolved := make(map[string]Profile, len(c.Profiles))
+	for name := range c.Profiles {
+		profile, err := r.resolve(name)
+		if err != nil {
+			return err
+		}
+		resolved[name] = profile
+	}
+	c.Profiles = resolved
+	if c.origins != nil {
+		c.origins = r.origins
+	}
+	return nil
+}
+
+// resolver merges profiles with their parents, remembering finished profiles
+type resolver struct {
+	raw      map[string]Profile
+	resolved map[string]Profile
+	// rawOrigins are the files the settings of raw profiles come from, and
+	// origins those of resolved profiles
+	rawOrigins map[originKey]string
+	origins    map[originKey]string
+	// chain is the path of profiles being resolved, used to report cycles
+	chain []string
+}
+
+func newResolver(raw map[string]Profile) *resolver {
+	return &resolver{raw: raw, resolved: make(map[string]Profile), origins: make(map[originKey]string)}
+}
+
+// inherit returns a function recording that settings of profile name come
+// from the given profile, resolved or raw
+func (r *resolver) inherit(name, from string, origins map[originKey]string) func(setting string) {
+	return func(setting string) {
+		if origin, ok := origins[originKey{from, setting}]; ok {
+			r.origins[originKey{name, setting}] = origin
+		}
+	}
+}
+
+func (r *resolver) resolve(name string) (Profile, error) {
+	if profile, ok := r.resolved[name]; ok {
+		return profile, nil
+	}
+	for i, n := range r.chain {
+		if n == name {
+			cycle := append(append([]string{}, r.chain[i:]...), name)
+			return Profile{}, &InheritanceError{Profile: name, Err: fmt.Errorf("extends itself through %s", strings.Join(cycle, " -> "))}
+		}
+	}
+
+	own := r.raw[name]
+	if len(own.Extends) == 0 {
+		mergeProfile(&Profile{}, &own, r.inherit(name, name, r.rawOrigins))
+		r.resolved[name] = own
+		return own, nil
+	}
+
+	r.chain = append(r.chain, name)
+	defer func() { r.chain = r.chain[:len(r.chain)-1] }()
+
+	var merged Profile
+	for _, parentName := range own.Extends {
+		if _, ok := r.raw[parentName]; !ok {
+			return Profile{}, &InheritanceError{Profile: name, Err: fmt.Errorf("extends unknown profile '%s'", parentName)}
+		}
+		parent, err := r.resolve(parentName)
+		if err != nil {
+			return Profile{}, err
+		}
+		mergeProfile(&merged, &parent, r.inherit(name, parentName, r.origins))
+	}
+	mergeProfile(&merged, &own, r.inherit(name, name, r.rawOrigins))
+	merged.Abstract = own.Abstract
+	merged.Extends = own.Extends
+
+	r.resolved[name] = merged
+	return merged, nil
+}
+
+// mergeProfile lays src over dst: maps are merged key by key, url_patterns
+// are combined, and other settings of src replace those of dst when set.
+// taken is called with every setting taken from src (see Config.Origin).
+func mergeProfile(dst, src *Profile, taken func(setting string)) {
+	d := reflect.ValueOf(dst).Elem()
+	s := reflect.ValueOf(src).Elem()
+	for i := 0; i < d.NumField(); i++ {
+		df, sf := d.Field(i), s.Field(i)
+		if sf.IsZero() {
+			continue
+		}
+		key, _, _ := strings.Cut(d.Type().Field(i).Tag.Get("yaml"), ",")
+		switch {
+		case sf.Kind() == reflect.Map:
+			merged := reflect.MakeMap(df.Type())
+			for _, m := range []reflect.Value{df, sf} {
+				for _, k := range m.MapKeys() {
+					merged.SetMapIndex(k, m.MapIndex(k))
+				}
+			}
+			df.Set(merged)
+			for _, k := range sf.MapKeys() {
+				taken(key + "." + k.String())
+			}
+		case key == "url_patterns":
+			patterns := append([]string{}, dst.URLPatterns...)
+			for _, pattern := range src.URLPatterns {
+				if !contains(patterns, pattern) {
+					patterns = append(patterns, pattern)
+					taken(key + "." + pattern)
+				}
+			}
+			dst.URLPatterns = patterns
+		default:
+			df.Set(sf)
+			taken(key)
+		}
+	}
+}
+
+// contains reports whether list holds item
+func contains(list []string, item string) bool {
+	for _, existing := range list {
+		if existing == item {
+			return true
+		}
+	}
+	return false
+}
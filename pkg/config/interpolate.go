@@ -0,0 +1,246 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MaskedValue replaces values read from secret files when the configuration is displayed
+const MaskedValue = "********"
+
+// variableNamePattern matches the environment variable names ${...} accepts
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// InterpolationError is returned when a ${...} reference in a profile setting cannot be expanded
+type InterpolationError struct {
+	Profile string
+	Setting string
+	Err     error
+}
+
+func (e *InterpolationError) Error() string {
+	return fmt.Sprintf("profile '%s': %s: %v", e.Profile, e.Setting, e.Err)
+}
+
+func (e *InterpolationError) Unwrap() error {
+	return e.Err
+}
+
+// Interpolate expands the references in a setting value: ${VAR} is the
+// environment variable VAR, ${VAR:-default} falls back to default when VAR is
+// unset or empty, and ${file:path} is the content of a file without its
+// trailing newline. Relative paths are taken from dir, and ~ is the home
+// directory. $${ stands for a literal ${. secret reports whether the value
+// includes the content of a file.
+func Interpolate(value, dir string) (expanded string, secret bool, err error) {
+	var out strings.Builder
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			out.WriteString(value)
+			return out.String(), secret, nil
+		}
+		if start > 0 && value[start-1] == '$' {
+			out.WriteString(value[:start] + "{")
+			value = value[start+2:]
+			continue
+		}
+
+		end := strings.IndexByte(value[start:], '}')
+		if end < 0 {
+			return "", false, fmt.Errorf("unterminated reference in %q, expected ${...}", value)
+		}
+		out.WriteString(value[:start])
+		reference := value[start+2 : start+end]
+		value = value[start+end+1:]
+
+		if path, ok := strings.CutPrefix(reference, "file:"); ok {
+			content, err := readSecret(path, dir)
+			if err != nil {
+				return "", false, err
+			}
+			out.WriteString(content)
+			secret = true
+			continue
+		}
+
+		name, fallback, hasFallback := strings.Cut(reference, ":-")
+		if !variableNamePattern.MatchString(name) {
+			return "", false, fmt.Errorf("invalid reference ${%s}, expected ${VAR}, ${VAR:-default} or ${file:path}", reference)
+		}
+		env, set := os.LookupEnv(name)
+		switch {
+		case hasFallback && env == "":
+			out.WriteString(fallback)
+		case !set:
+			return "", false, fmt.Errorf("environment variable %s is not set, set it or use ${%s:-default}", name, name)
+		default:
+			out.WriteString(env)
+		}
+	}
+}
+
+// readSecret returns the content of a ${file:path} reference
+func readSecret(path, dir string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("empty path in ${file:}")
+	}
+	resolved := ExpandHome(path)
+	if !filepath.IsAbs(resolved) && dir != "" {
+		resolved = filepath.Join(dir, resolved)
+	}
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("secret file %s does not exist", path)
+		}
+		return "", fmt.Errorf("error reading secret file %s: %w", path, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// interpolate expands the references in the ssh_host, url_patterns and
+// git_configs of every profile that is not abstract, recording the settings
+// that came from secret files. Relative secret paths are taken from the
+// directory of the file each setting was read from, or of configFile. A
+// profile whose references cannot be expanded is left as written and its
+// error is recorded, see ProfileError, so it only fails the commands using it.
+func (c *Config) interpolate(configFile string) {
+	c.secrets = make(map[originKey]bool)
+	c.unresolved = make(map[string]error)
+	for name, profile := range c.Profiles {
+		if profile.Abstract {
+			continue
+		}
+		if err := c.interpolateProfile(name, &profile, configFile); err != nil {
+			c.unresolved[name] = err
+			continue
+		}
+		c.Profiles[name] = profile
+	}
+}
+
+// interpolateProfile expands the references of one profile in place
+func (c *Config) interpolateProfile(name string, profile *Profile, configFile string) error {
+	secrets := make(map[string]bool)
+	expand := func(setting, value string) (string, error) {
+		file := c.Origin(name, setting)
+		if file == "" {
+			file = configFile
+		}
+		expanded, secret, err := Interpolate(value, filepath.Dir(file))
+		if err != nil {
+			return "", &ConfigError{Path: file, Err: &InterpolationError{Profile: name, Setting: setting, Err: err}}
+		}
+		if secret {
+			secrets[setting] = true
+		}
+		return expanded, nil
+	}
+
+	sshHost, err := expand("ssh_host", profile.SSHHost)
+	if err != nil {
+		return err
+	}
+
+	patterns := make([]string, 0, len(profile.URLPatterns))
+	for _, pattern := range profile.URLPatterns {
+		expanded, err := expand("url_patterns."+pattern, pattern)
+		if err != nil {
+			return err
+		}
+		patterns = append(patterns, expanded)
+	}
+
+	keys := make([]string, 0, len(profile.GitConfigs))
+	for key := range profile.GitConfigs {
+		keys = append(keys, key)
+	}
+	// Report the same error first every time
+	sort.Strings(keys)
+	configs := make(map[string]string, len(profile.GitConfigs))
+	for _, key := range keys {
+		if configs[key], err = expand("git_configs."+key, profile.GitConfigs[key]); err != nil {
+			return err
+		}
+	}
+
+	for setting := range secrets {
+		c.secrets[originKey{name, setting}] = true
+	}
+	for i, pattern := range profile.URLPatterns {
+		if patterns[i] != pattern {
+			c.renameSetting(name, "url_patterns."+pattern, "url_patterns."+patterns[i])
+		}
+	}
+	profile.SSHHost = sshHost
+	if profile.URLPatterns != nil {
+		profile.URLPatterns = patterns
+	}
+	if profile.GitConfigs != nil {
+		profile.GitConfigs = configs
+	}
+	return nil
+}
+
+// ProfileError returns the error expanding the references of a profile, or
+// nil when they were expanded. Such a profile keeps its settings as written.
+func (c *Config) ProfileError(name string) error {
+	return c.unresolved[name]
+}
+
+// renameSetting moves what is recorded about a setting to a new name
+func (c *Config) renameSetting(profile, from, to string) {
+	if origin, ok := c.origins[originKey{profile, from}]; ok {
+		delete(c.origins, originKey{profile, from})
+		c.origins[originKey{profile, to}] = origin
+	}
+	if c.secrets[originKey{profile, from}] {
+		delete(c.secrets, originKey{profile, from})
+		c.secrets[originKey{profile, to}] = true
+	}
+}
+
+// IsSecret reports whether a setting (named as in Origin) was read from a secret file
+func (c *Config) IsSecret(profile, setting string) bool {
+	return c.secrets[originKey{profile, setting}]
+}
+
+// Masked returns a copy of the configuration for display, with the values
+// read from secret files replaced by MaskedValue
+func (c *Config) Masked() *Config {
+	masked := *c
+	masked.Profiles = make(map[string]Profile, len(c.Profiles))
+	for name, profile := range c.Profiles {
+		if profile.SSHHost != "" && c.IsSecret(name, "ssh_host") {
+			profile.SSHHost = MaskedValue
+		}
+
+		patterns := make([]string, len(profile.URLPatterns))
+		for i, pattern := range profile.URLPatterns {
+			patterns[i] = pattern
+			if c.IsSecret(name, "url_patterns."+pattern) {
+				patterns[i] = MaskedValue
+			}
+		}
+		if profile.URLPatterns != nil {
+			profile.URLPatterns = patterns
+		}
+
+		configs := make(map[string]string, len(profile.GitConfigs))
+		for key, value := range profile.GitConfigs {
+			configs[key] = value
+			if c.IsSecret(name, "git_configs."+key) {
+				configs[key] = MaskedValue
+			}
+		}
+		profile.GitConfigs = configs
+
+		masked.Profiles[name] = profile
+	}
+	return &masked
+}
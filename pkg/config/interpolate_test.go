@@ -0,0 +1,51 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigKeepsUnresolvedProfilesApart(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GCLONE_TEST_HOST", "alias-work")
+	file := filepath.Join(t.TempDir(), "config.yml")
+	input := `version: 1
+profiles:
+  work:
+    name: Work
+    ssh_host: ${GCLONE_TEST_HOST}
+  broken:
+    name: Broken
+    ssh_host: ${GCLONE_TEST_UNSET}
+`
+	if err := os.WriteFile(file, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(file)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v, want the unset variable kept to its profile", err)
+	}
+
+	work, err := cfg.GetProfile("work")
+	if err != nil {
+		t.Fatalf("GetProfile(work) error = %v", err)
+	}
+	if work.SSHHost != "alias-work" {
+		t.Errorf("work ssh_host = %q, want alias-work", work.SSHHost)
+	}
+
+	_, err = cfg.GetProfile("broken")
+	var interpolationErr *InterpolationError
+	if !errors.As(err, &interpolationErr) || interpolationErr.Profile != "broken" {
+		t.Errorf("GetProfile(broken) error = %v, want an InterpolationError", err)
+	}
+	if cfg.ProfileError("work") != nil {
+		t.Errorf("ProfileError(work) = %v, want nil", cfg.ProfileError("work"))
+	}
+	if got := cfg.Profiles["broken"].SSHHost; got != "${GCLONE_TEST_UNSET}" {
+		t.Errorf("broken ssh_host = %q, want it as written", got)
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/user-cube/gclone/pkg/ui"
+)
+
+// SystemConfigFile is the machine-wide config file, the lowest layer
+const SystemConfigFile = "/etc/gclone/config.yml"
+
+// originKey names one setting of a profile: a profile field's YAML key,
+// git_configs.<key> or url_patterns.<pattern>
+type originKey struct {
+	profile string
+	setting string
+}
+
+// XDGConfigFile returns the config file in the XDG config directory,
+// $XDG_CONFIG_HOME/gclone/config.yml or ~/.config/gclone/config.yml
+func XDGConfigFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "gclone", "config.yml")
+}
+
+// DropInDir returns the directory of config snippets merged before the user's file
+func DropInDir() string {
+	return filepath.Join(DefaultConfigDir(), "config.d")
+}
+
+// LayerFiles returns the files merged by LoadConfig, from lowest to highest
+// precedence: the system file, the files of subscribed shared repositories,
+// the XDG file, the config.d snippets in name order, and last the given file
+// (the default one when empty), which is the only one gclone writes. Files
+// that do not exist are included.
+func LayerFiles(configFile string) []string {
+	if configFile == "" {
+		configFile = DefaultConfigFile()
+	}
+
+	files := append([]string{SystemConfigFile}, sharedFiles()...)
+	if xdg := XDGConfigFile(); xdg != "" {
+		files = append(files, xdg)
+	}
+	dropIns, _ := filepath.Glob(filepath.Join(DropInDir(), "*.yml"))
+	sort.Strings(dropIns)
+	files = append(files, dropIns...)
+
+	// The user's file takes its own place even when it is also one of the others
+	layers := make([]string, 0, len(files)+1)
+	for _, file := range files {
+		if !sameFile(file, configFile) {
+			layers = append(layers, file)
+		}
+	}
+	return append(layers, configFile)
+}
+
+// MergeLayers lays user, the content of configFile, over the other layers
+// read from disk. Profiles defined in several files are merged setting by
+// setting like profile inheritance, with the higher layer winning. Inheritance
+// itself is not resolved. The origin of every setting is recorded.
+func MergeLayers(configFile string, user *Config) (*Config, error) {
+	files := LayerFiles(configFile)
+
+	merged := &Config{
+		Version:  user.Version,
+		Profiles: make(map[string]Profile),
+		origins:  make(map[originKey]string),
+	}
+	for _, file := range files[:len(files)-1] {
+		// Only the user's own file is ever rewritten, others are upgraded in memory
+		layer, err := loadFile(file, false)
+		if err != nil {
+			return nil, err
+		}
+		merged.overlay(file, layer)
+	}
+	merged.overlay(files[len(files)-1], user)
+
+	return merged, nil
+}
+
+// overlay merges the profiles of one layer into the configuration
+func (c *Config) overlay(file string, layer *Config) {
+	for name, profile := range layer.Profiles {
+		// One bad name must not stop every command, 'gclone config validate' reports it
+		if err := ValidateProfileName(name); err != nil {
+			warnOnce(file+"\x00"+name, "%s: %v, the profile is ignored\n", file, err)
+			continue
+		}
+		existing := c.Profiles[name]
+		mergeProfile(&existing, &profile, func(setting string) {
+			c.origins[originKey{name, setting}] = file
+		})
+		c.Profiles[name] = existing
+	}
+}
+
+// Origin returns the file a setting of a profile was read from, or an empty
+// string when unknown. setting is a profile field's YAML key (such as
+// ssh_host), git_configs.<key> or url_patterns.<pattern>. Inherited settings
+// report the file of the profile they were inherited from.
+func (c *Config) Origin(profile, setting string) string {
+	return c.origins[originKey{profile, setting}]
+}
+
+// ExistingLayerFiles returns the layer files that exist
+func ExistingLayerFiles(configFile string) []string {
+	var existing []string
+	for _, file := range LayerFiles(configFile) {
+		if _, err := os.Stat(file); err == nil {
+			existing = append(existing, file)
+		}
+	}
+	return existing
+}
+
+// sameFile reports whether two paths name the same file
+func sameFile(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
+
+// warned holds the keys of the warnings warnOnce already printed
+var warned = make(map[string]bool)
+
+// warnOnce prints a warning the first time it is given key, as the layers
+// are often merged more than once by a single command
+func warnOnce(key, format string, args ...interface{}) {
+	if !warned[key] {
+		warned[key] = true
+		ui.Warning(format, args...)
+	}
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/user-cube/gclone/pkg/fileutil"
+	"github.com/user-cube/gclone/pkg/ui"
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaVersion is the config file format written by this version of gclone.
+// Files without a version key are version 0.
+const SchemaVersion = 1
+
+// migration upgrades a config document from one schema version to the next
+type migration struct {
+	description string
+	apply       func(root *yaml.Node) error
+}
+
+// migrations[i] upgrades a document from version i to version i+1. New schema
+// versions only ever append here, so any older file can be brought up to date.
+var migrations = []migration{
+	{
+		description: "add the version key",
+		// Version 1 only starts recording the version, set by upgradeDocument
+		apply: func(root *yaml.Node) error { return nil },
+	},
+}
+
+// NewerSchemaError is returned when a config file was written by a newer gclone
+type NewerSchemaError struct {
+	Version int
+}
+
+func (e *NewerSchemaError) Error() string {
+	return fmt.Sprintf("config file uses schema version %d but this gclone only supports up to version %d, upgrade gclone", e.Version, SchemaVersion)
+}
+
+// documentVersion returns the version key of a config document, 0 when missing
+func documentVersion(root *yaml.Node) (int, error) {
+	node := mappingValue(root, "version")
+	if node == nil {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(node.Value)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid version %q", node.Value)
+	}
+	return version, nil
+}
+
+// upgradeDocument runs the migrations needed to bring a config document to
+// SchemaVersion and stamps the new version. It reports whether anything changed.
+func upgradeDocument(root *yaml.Node) (bool, error) {
+	version, err := documentVersion(root)
+	if err != nil {
+		return false, err
+	}
+	if version > SchemaVersion {
+		return false, &NewerSchemaError{Version: version}
+	}
+	if version == SchemaVersion {
+		return false, nil
+	}
+
+	for v := version; v < SchemaVersion; v++ {
+		if err := migrations[v].apply(root); err != nil {
+			return false, fmt.Errorf("error migrating config to version %d (%s): %w", v+1, migrations[v].description, err)
+		}
+	}
+	setMappingValue(root, "version", strconv.Itoa(SchemaVersion))
+	return true, nil
+}
+
+// migrateFile rewrites a config file after upgrading it, keeping the original
+// next to it. A file that cannot be rewritten is still used as migrated in
+// memory. The rewrite is left for a later run while another gclone process
+// holds the lock of the file, or when the file changed since it was read.
+func migrateFile(configFile string, original []byte, root *yaml.Node, fromVersion int) {
+	lock, ok, err := TryLockConfig(configFile)
+	if err != nil || !ok {
+		return
+	}
+	defer func() {
+		_ = lock.Unlock()
+	}()
+	if current, err := os.ReadFile(configFile); err != nil || !bytes.Equal(current, original) {
+		return
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", configFile, fromVersion)
+	if _, err := os.Stat(backup); os.IsNotExist(err) {
+		if err := fileutil.WriteFileAtomic(backup, original, 0644); err != nil {
+			ui.Warning("Could not back up %s before upgrading it: %v\n", configFile, err)
+			return
+		}
+	}
+
+	data, err := yaml.Marshal(root)
+	if err != nil {
+		ui.Warning("Could not upgrade %s: %v\n", configFile, err)
+		return
+	}
+	// Prefer a rewrite that keeps the blank lines and indentation of the original
+	var marked yaml.Node
+	if yaml.Unmarshal(markBlankLines(original), &marked) == nil {
+		if _, err := upgradeDocument(&marked); err == nil {
+			if kept, err := encodeDocument(&marked); err == nil && sameConfig(kept, data) {
+				data = kept
+			}
+		}
+	}
+	if err := WriteConfigFile(configFile, data); err != nil {
+		ui.Warning("Could not upgrade %s: %v\n", configFile, err)
+		return
+	}
+
+	ui.Info("Upgraded %s from schema version %d to %d (backup at %s)\n", configFile, fromVersion, SchemaVersion, backup)
+}
+
+// mappingValue returns the value node of a key in the top-level mapping of a document
+func mappingValue(root *yaml.Node, key string) *yaml.Node {
+	mapping := documentMapping(root)
+	if mapping == nil {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets a scalar key in the top-level mapping of a document,
+// adding it at the top when missing
+func setMappingValue(root *yaml.Node, key, value string) {
+	mapping := documentMapping(root)
+	if mapping == nil {
+		return
+	}
+	if node := mappingValue(root, key); node != nil {
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "", value
+		return
+	}
+	mapping.Content = append([]*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: key},
+		{Kind: yaml.ScalarNode, Value: value},
+	}, mapping.Content...)
+}
+
+// documentMapping returns the top-level mapping node of a document
+func documentMapping(root *yaml.Node) *yaml.Node {
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil
+	}
+	return root
+}
@@ -0,0 +1,385 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/user-cube/gclone/pkg/ui"
+	"gopkg.in/yaml.v3"
+)
+
+// blankLineMarker stands in for blank lines while a config document is a
+// node tree, since yaml.v3 keeps comments but drops blank lines
+const blankLineMarker = "#gclone:blank"
+
+// encodeConfig returns the YAML of a configuration. When the previous content
+// of its file is given, that document is edited instead of writing a fresh
+// one, so comments, key order, anchors and formatting survive. Should the
+// edited document not read back as the configuration, the fresh one is used
+// and a warning names the file.
+func encodeConfig(config *Config, previous []byte, configFile string) ([]byte, error) {
+	fresh, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(previous)) == 0 {
+		return fresh, nil
+	}
+
+	var root yaml.Node
+	if yaml.Unmarshal(markBlankLines(previous), &root) != nil || documentMapping(&root) == nil {
+		warnRewrite(configFile)
+		return fresh, nil
+	}
+
+	var want yaml.Node
+	if err := want.Encode(config); err != nil {
+		return nil, err
+	}
+	editNode(documentMapping(&root), &want, nil)
+
+	edited, err := encodeDocument(&root)
+	if err != nil || !sameConfig(edited, fresh) {
+		warnRewrite(configFile)
+		return fresh, nil
+	}
+	return edited, nil
+}
+
+// warnRewrite tells the user a config file lost its comments and layout
+func warnRewrite(configFile string) {
+	ui.Warning("Could not keep the comments and layout of %s, it was rewritten (the previous version is in %s)\n", configFile, BackupDir())
+}
+
+// encodeDocument writes a document parsed from markBlankLines output back
+// with the indentation it was written in and its blank lines
+func encodeDocument(root *yaml.Node) ([]byte, error) {
+	untagMergeKeys(root)
+
+	indent := 4
+	if mapping := documentMapping(root); mapping != nil {
+		indent = documentIndent(mapping)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(indent)
+	if err := encoder.Encode(root); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return unmarkBlankLines(buf.Bytes()), nil
+}
+
+// editNode changes dst, a node of the previous document, to hold the data of
+// src, the freshly encoded one, reusing the nodes of dst (and so their
+// comments) wherever the data did not change. path is the keys leading to dst.
+func editNode(dst, src *yaml.Node, path []string) {
+	if sameData(dst, src) {
+		return
+	}
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		editMapping(dst, src, path)
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		editSequence(dst, src)
+	default:
+		replaceNode(dst, src)
+	}
+}
+
+// editMapping keeps the entries of dst in their order, drops those src no
+// longer has and appends the new ones
+func editMapping(dst, src *yaml.Node, path []string) {
+	wanted := make(map[string]*yaml.Node, len(src.Content)/2)
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		wanted[src.Content[i].Value] = src.Content[i+1]
+	}
+
+	content := make([]*yaml.Node, 0, len(src.Content))
+	seen := make(map[string]bool, len(wanted))
+	for i := 0; i+1 < len(dst.Content); i += 2 {
+		key, value := dst.Content[i], dst.Content[i+1]
+		if want, ok := wanted[key.Value]; ok {
+			editNode(value, want, append(path, key.Value))
+			seen[key.Value] = true
+		} else if !unknownKey(path, key.Value) {
+			// A removed entry takes the blank line after it along, kept in its
+			// own foot comment, except the last, which has none
+			if i >= 2 && i+2 == len(dst.Content) && blankBefore(dst.Content, i) {
+				if node := lastFootNode(dst.Content[i-2], dst.Content[i-1]); node != nil {
+					node.FootComment = strings.TrimSuffix(strings.TrimSuffix(node.FootComment, blankLineMarker), "\n")
+				}
+			}
+			continue
+		}
+		content = append(content, key, value)
+	}
+
+	inherited := mergedValues(dst)
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		if seen[key.Value] {
+			continue
+		}
+		if from, ok := inherited[key.Value]; ok && sameData(from, value) {
+			continue
+		}
+		// Space new entries out like the ones before them
+		if n := len(content); n > 0 && blankBefore(content, n-2) {
+			key.HeadComment = blankLineMarker
+		}
+		content = append(content, key, value)
+	}
+
+	// Comments after the last entry, often the blank line before the next
+	// section, stay at the end
+	if n := len(dst.Content); n > 0 && len(content) > 0 && content[len(content)-2] != dst.Content[n-2] {
+		if foot := takeFootComment(dst.Content[n-2], dst.Content[n-1]); foot != "" {
+			content[len(content)-2].FootComment = foot
+		}
+	}
+	dst.Content = content
+}
+
+// mergedValues returns the entries a mapping takes from others with <<
+func mergedValues(mapping *yaml.Node) map[string]*yaml.Node {
+	values := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].ShortTag() != "!!merge" {
+			continue
+		}
+		sources := []*yaml.Node{mapping.Content[i+1]}
+		if sources[0].Kind == yaml.SequenceNode {
+			sources = sources[0].Content
+		}
+		// Earlier sources win, as when decoding
+		for j := len(sources) - 1; j >= 0; j-- {
+			source := sources[j]
+			if source.Kind == yaml.AliasNode {
+				source = source.Alias
+			}
+			if source == nil || source.Kind != yaml.MappingNode {
+				continue
+			}
+			for k := 0; k+1 < len(source.Content); k += 2 {
+				values[source.Content[k].Value] = source.Content[k+1]
+			}
+		}
+	}
+	return values
+}
+
+// blankBefore reports whether a blank line precedes the entry of a mapping
+// whose key is at content[i]
+func blankBefore(content []*yaml.Node, i int) bool {
+	if strings.HasPrefix(content[i].HeadComment, blankLineMarker) {
+		return true
+	}
+	return i >= 2 && strings.HasSuffix(footComment(content[i-2], content[i-1]), blankLineMarker)
+}
+
+// footComment returns the comment after an entry, which yaml.v3 attaches to
+// the innermost node it follows
+func footComment(key, value *yaml.Node) string {
+	node := lastFootNode(key, value)
+	if node == nil {
+		return ""
+	}
+	return node.FootComment
+}
+
+// takeFootComment removes the comment after an entry and returns it
+func takeFootComment(key, value *yaml.Node) string {
+	node := lastFootNode(key, value)
+	if node == nil {
+		return ""
+	}
+	foot := node.FootComment
+	node.FootComment = ""
+	return foot
+}
+
+// lastFootNode returns the node holding the comment after an entry, if any
+func lastFootNode(key, value *yaml.Node) *yaml.Node {
+	if value.FootComment != "" {
+		return value
+	}
+	if key.FootComment != "" {
+		return key
+	}
+	switch n := len(value.Content); {
+	case value.Kind == yaml.MappingNode && n >= 2:
+		return lastFootNode(value.Content[n-2], value.Content[n-1])
+	case value.Kind == yaml.SequenceNode && n >= 1:
+		item := value.Content[n-1]
+		if item.FootComment != "" {
+			return item
+		}
+	}
+	return nil
+}
+
+// editSequence lists the items of src, reusing the matching items of dst
+func editSequence(dst, src *yaml.Node) {
+	used := make([]bool, len(dst.Content))
+	content := make([]*yaml.Node, 0, len(src.Content))
+	for _, want := range src.Content {
+		item := want
+		for i, have := range dst.Content {
+			if !used[i] && sameData(have, want) {
+				used[i], item = true, have
+				break
+			}
+		}
+		content = append(content, item)
+	}
+	dst.Content = content
+}
+
+// replaceNode overwrites dst with src, keeping the comments and anchor of dst
+// and the quoting of strings
+func replaceNode(dst, src *yaml.Node) {
+	old := *dst
+	*dst = *src
+	dst.HeadComment, dst.LineComment, dst.FootComment = old.HeadComment, old.LineComment, old.FootComment
+	dst.Anchor = old.Anchor
+	quoted := yaml.SingleQuotedStyle | yaml.DoubleQuotedStyle
+	if old.Kind == yaml.ScalarNode && src.Kind == yaml.ScalarNode && src.ShortTag() == "!!str" && old.Style&quoted != 0 {
+		dst.Style = old.Style
+	}
+}
+
+// unknownKey reports whether a key of the mapping at path is not part of the
+// Config schema. Such keys, for example ones only holding anchors, are kept.
+func unknownKey(path []string, key string) bool {
+	switch len(path) {
+	case 0:
+		return key != "version" && key != "profiles"
+	case 2:
+		return !profileKeys()[key]
+	}
+	return false
+}
+
+// untagMergeKeys clears the tag of << keys, which yaml.v3 would otherwise
+// write out as "!!merge <<"
+func untagMergeKeys(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i < len(node.Content); i += 2 {
+			if node.Content[i].ShortTag() == "!!merge" {
+				node.Content[i].Tag = ""
+			}
+		}
+	}
+	for _, child := range node.Content {
+		untagMergeKeys(child)
+	}
+}
+
+// sameData reports whether two nodes decode to the same data, following
+// aliases and merge keys
+func sameData(a, b *yaml.Node) bool {
+	var x, y interface{}
+	if a.Decode(&x) != nil || b.Decode(&y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// sameConfig reports whether two documents hold the same configuration
+func sameConfig(a, b []byte) bool {
+	var x, y Config
+	if yaml.Unmarshal(a, &x) != nil || yaml.Unmarshal(b, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// documentIndent returns the indentation of a document's nested blocks, or
+// the 4 spaces yaml.Marshal uses when it has none
+func documentIndent(mapping *yaml.Node) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		if value.Kind == yaml.MappingNode && value.Style&yaml.FlowStyle == 0 && len(value.Content) > 0 {
+			if indent := value.Content[0].Column - key.Column; indent >= 2 && indent <= 8 {
+				return indent
+			}
+		}
+	}
+	return 4
+}
+
+// markBlankLines replaces blank lines with blankLineMarker comments. Blank
+// lines that belong to a multi-line scalar, such as a | block, are content
+// and left alone; so is a document that does not parse.
+func markBlankLines(data []byte) []byte {
+	var root yaml.Node
+	if yaml.Unmarshal(data, &root) != nil {
+		return data
+	}
+	lines := strings.Split(string(data), "\n")
+	protected := scalarLines(&root, len(lines))
+	for i, line := range lines[:len(lines)-1] {
+		if strings.TrimSpace(line) == "" && !protected[i+1] {
+			lines[i] = blankLineMarker
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// scalarLines returns the line numbers (from 1) that may hold the content of
+// multi-line scalars: from the line such a scalar starts on up to the line
+// before the next node, or the last of the document's lineCount lines
+func scalarLines(root *yaml.Node, lineCount int) map[int]bool {
+	var starts []int
+	var multiLine []*yaml.Node
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if node.Kind != yaml.DocumentNode {
+			starts = append(starts, node.Line)
+		}
+		block := yaml.LiteralStyle | yaml.FoldedStyle
+		if node.Kind == yaml.ScalarNode && (node.Style&block != 0 || strings.Contains(node.Value, "\n")) {
+			multiLine = append(multiLine, node)
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	walk(root)
+	sort.Ints(starts)
+
+	protected := make(map[int]bool)
+	for _, node := range multiLine {
+		start := node.Line
+		end := lineCount + 1
+		if i := sort.SearchInts(starts, start+1); i < len(starts) {
+			end = starts[i]
+		}
+		// A | block has a line per newline of its value, the blank lines after
+		// it are not content unless kept with |+, which puts them in the value
+		if node.Style&yaml.LiteralStyle != 0 {
+			end = min(end, start+strings.Count(node.Value, "\n")+1)
+		}
+		for line := start; line < end; line++ {
+			protected[line] = true
+		}
+	}
+	return protected
+}
+
+// unmarkBlankLines turns blankLineMarker comments back into blank lines
+func unmarkBlankLines(data []byte) []byte {
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == blankLineMarker {
+			lines[i] = ""
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,531 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestEncodeConfigKeepsDocument(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		change func(cfg *Config)
+		want   string
+	}{
+		{
+			name: "comments survive a change",
+			input: `# gclone configuration
+version: 1
+profiles:
+  # personal account
+  personal:
+    name: Personal # shown in prompts
+    ssh_host: github.com-personal
+    # trailing comment of personal
+`,
+			change: func(cfg *Config) { setSSHHost(cfg, "personal", "github.com-me") },
+			want: `# gclone configuration
+version: 1
+profiles:
+  # personal account
+  personal:
+    name: Personal # shown in prompts
+    ssh_host: github.com-me
+    # trailing comment of personal
+`,
+		},
+		{
+			name: "key order is kept and new keys go last",
+			input: `profiles:
+  work:
+    ssh_host: alias-work
+    name: Work
+version: 1
+`,
+			change: func(cfg *Config) { setConfigMode(cfg, "work", ConfigModeInclude) },
+			want: `profiles:
+  work:
+    ssh_host: alias-work
+    name: Work
+    config_mode: include
+version: 1
+`,
+		},
+		{
+			name: "anchors, aliases and merge keys survive",
+			input: `version: 1
+x-base: &base
+  config_mode: include
+  identity_guard: pre-commit
+profiles:
+  work:
+    <<: *base
+    name: Work
+    ssh_host: alias-work
+  oss:
+    <<: *base
+    name: OSS
+    ssh_host: alias-oss
+`,
+			change: func(cfg *Config) { setSSHHost(cfg, "oss", "alias-oss2") },
+			want: `version: 1
+x-base: &base
+  config_mode: include
+  identity_guard: pre-commit
+profiles:
+  work:
+    <<: *base
+    name: Work
+    ssh_host: alias-work
+  oss:
+    <<: *base
+    name: OSS
+    ssh_host: alias-oss2
+`,
+		},
+		{
+			name: "a merged setting is overridden in the profile",
+			input: `version: 1
+x-base: &base
+  config_mode: include
+profiles:
+  work:
+    <<: *base
+    name: Work
+    ssh_host: alias-work
+`,
+			change: func(cfg *Config) { setConfigMode(cfg, "work", ConfigModeCopy) },
+			want: `version: 1
+x-base: &base
+  config_mode: include
+profiles:
+  work:
+    <<: *base
+    name: Work
+    ssh_host: alias-work
+    config_mode: copy
+`,
+		},
+		{
+			name: "blank lines between profiles are kept",
+			input: `version: 1
+
+profiles:
+  a:
+    name: A
+    ssh_host: alias-a
+
+  b:
+    name: B
+    ssh_host: alias-b
+`,
+			change: func(cfg *Config) { setSSHHost(cfg, "a", "alias-a2") },
+			want: `version: 1
+
+profiles:
+  a:
+    name: A
+    ssh_host: alias-a2
+
+  b:
+    name: B
+    ssh_host: alias-b
+`,
+		},
+		{
+			name: "block scalars keep their blank lines",
+			input: `version: 1
+profiles:
+  work:
+    name: Work
+    ssh_host: alias-work
+    git_configs:
+      commit.template: |
+        Subject
+
+        Body
+
+      user.email: w@example.com
+  # home
+  home:
+    name: Home
+    ssh_host: alias-home
+`,
+			change: func(cfg *Config) { setSSHHost(cfg, "home", "alias-home2") },
+			want: `version: 1
+profiles:
+  work:
+    name: Work
+    ssh_host: alias-work
+    git_configs:
+      commit.template: |
+        Subject
+
+        Body
+
+      user.email: w@example.com
+  # home
+  home:
+    name: Home
+    ssh_host: alias-home2
+`,
+		},
+		{
+			name: "indentation of four spaces is kept",
+			input: `version: 1
+profiles:
+    work:
+        name: Work
+        ssh_host: alias-work
+`,
+			change: func(cfg *Config) { setSSHHost(cfg, "work", "alias-work2") },
+			want: `version: 1
+profiles:
+    work:
+        name: Work
+        ssh_host: alias-work2
+`,
+		},
+		{
+			name: "an added profile follows the spacing of the others",
+			input: `version: 1
+profiles:
+  # personal
+  personal:
+    name: Personal
+    ssh_host: alias-personal
+
+  # work
+  work:
+    name: Work
+    ssh_host: alias-work
+`,
+			change: func(cfg *Config) {
+				cfg.Profiles["oss"] = Profile{Name: "OSS", SSHHost: "alias-oss", URLPatterns: []string{"github.com/oss"}}
+			},
+			want: `version: 1
+profiles:
+  # personal
+  personal:
+    name: Personal
+    ssh_host: alias-personal
+
+  # work
+  work:
+    name: Work
+    ssh_host: alias-work
+
+  oss:
+    name: OSS
+    ssh_host: alias-oss
+    url_patterns:
+      - github.com/oss
+`,
+		},
+		{
+			name: "a removed profile takes its comments and blank line along",
+			input: `version: 1
+profiles:
+  # personal
+  personal:
+    name: Personal
+    ssh_host: alias-personal
+
+  # work
+  work:
+    name: Work
+    ssh_host: alias-work
+
+  # oss
+  oss:
+    name: OSS
+    ssh_host: alias-oss
+`,
+			change: func(cfg *Config) { delete(cfg.Profiles, "work") },
+			want: `version: 1
+profiles:
+  # personal
+  personal:
+    name: Personal
+    ssh_host: alias-personal
+
+  # oss
+  oss:
+    name: OSS
+    ssh_host: alias-oss
+`,
+		},
+		{
+			name: "removing the last profile leaves no blank line behind",
+			input: `version: 1
+profiles:
+  personal:
+    name: Personal
+    ssh_host: alias-personal
+
+  work:
+    name: Work
+    ssh_host: alias-work
+`,
+			change: func(cfg *Config) { delete(cfg.Profiles, "work") },
+			want: `version: 1
+profiles:
+  personal:
+    name: Personal
+    ssh_host: alias-personal
+`,
+		},
+		{
+			name: "unknown top-level keys are kept",
+			input: `version: 1
+x-note: managed by hand
+profiles:
+  work:
+    name: Work
+    ssh_host: alias-work
+`,
+			change: func(cfg *Config) { delete(cfg.Profiles, "work") },
+			want: `version: 1
+x-note: managed by hand
+profiles: {}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			got := roundTrip(t, tt.input, tt.change)
+			if got != tt.want {
+				t.Errorf("encodeConfig() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeConfigFallsBack(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		change func(cfg *Config)
+	}{
+		{
+			// The setting comes from the anchor, which other profiles share
+			name: "removing a merged setting",
+			input: `version: 1
+x-base: &base
+  config_mode: include
+profiles:
+  # work
+  work:
+    <<: *base
+    name: Work
+    ssh_host: alias-work
+`,
+			change: func(cfg *Config) { setConfigMode(cfg, "work", "") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			cfg, got := roundTripConfig(t, tt.input, tt.change)
+			want, err := yaml.Marshal(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("encodeConfig() =\n%s\nwant the plain encoding\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestEncodeConfigWithoutPreviousFile(t *testing.T) {
+	cfg := &Config{Version: SchemaVersion, Profiles: map[string]Profile{"work": {Name: "Work", SSHHost: "alias-work"}}}
+	got, err := encodeConfig(cfg, nil, "config.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := yaml.Marshal(cfg)
+	if string(got) != string(want) {
+		t.Errorf("encodeConfig() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMigrateFileKeepsDocument(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name: "comments, blank lines and indentation",
+			input: `# my profiles
+
+profiles:
+  # work
+  work:
+    name: Work # the day job
+    ssh_host: alias-work
+
+  home:
+    name: Home
+    ssh_host: alias-home
+`,
+			want: `version: 1
+# my profiles
+
+profiles:
+  # work
+  work:
+    name: Work # the day job
+    ssh_host: alias-work
+
+  home:
+    name: Home
+    ssh_host: alias-home
+`,
+		},
+		{
+			name: "anchors and block scalars",
+			input: `x-base: &base
+    config_mode: include
+profiles:
+    work:
+        <<: *base
+        name: Work
+        ssh_host: alias-work
+        git_configs:
+            commit.template: |
+                Subject
+
+                Body
+`,
+			want: `version: 1
+x-base: &base
+    config_mode: include
+profiles:
+    work:
+        <<: *base
+        name: Work
+        ssh_host: alias-work
+        git_configs:
+            commit.template: |
+                Subject
+
+                Body
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			file := filepath.Join(t.TempDir(), "config.yml")
+			if err := os.WriteFile(file, []byte(tt.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := LoadConfigFile(file); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("migrated file =\n%s\nwant\n%s", got, tt.want)
+			}
+			backup, err := os.ReadFile(file + ".v0.bak")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(backup) != tt.input {
+				t.Errorf("backup =\n%s\nwant the original\n%s", backup, tt.input)
+			}
+		})
+	}
+}
+
+func TestMarkBlankLines(t *testing.T) {
+	input := strings.Join([]string{
+		"a: 1",
+		"",
+		"b: |",
+		"  x",
+		"",
+		"  y",
+		"",
+		"c: |+",
+		"  z",
+		"",
+		"d: 2",
+		"",
+	}, "\n")
+	want := strings.Join([]string{
+		"a: 1",
+		blankLineMarker,
+		"b: |",
+		"  x",
+		"",
+		"  y",
+		blankLineMarker,
+		"c: |+",
+		"  z",
+		"",
+		"d: 2",
+		"",
+	}, "\n")
+
+	if got := string(markBlankLines([]byte(input))); got != want {
+		t.Errorf("markBlankLines() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// roundTrip writes input to a config file, loads it, applies change and returns what SaveConfig would write
+func roundTrip(t *testing.T, input string, change func(cfg *Config)) string {
+	t.Helper()
+	_, got := roundTripConfig(t, input, change)
+	return got
+}
+
+func roundTripConfig(t *testing.T, input string, change func(cfg *Config)) (*Config, string) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(file, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfigFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	change(cfg)
+
+	previous, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := encodeConfig(cfg, previous, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg, string(got)
+}
+
+func setSSHHost(cfg *Config, name, host string) {
+	profile := cfg.Profiles[name]
+	profile.SSHHost = host
+	cfg.Profiles[name] = profile
+}
+
+func setConfigMode(cfg *Config, name, mode string) {
+	profile := cfg.Profiles[name]
+	profile.ConfigMode = mode
+	cfg.Profiles[name] = profile
+}
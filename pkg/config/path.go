@@ -0,0 +1,327 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PathError is returned for config paths that do not name a setting, and for
+// values that do not fit the setting's type
+type PathError struct {
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// NotSetError is returned when getting or unsetting a setting that has no value
+type NotSetError struct {
+	Path string
+}
+
+func (e *NotSetError) Error() string {
+	return fmt.Sprintf("%s is not set", e.Path)
+}
+
+// SplitPath splits a dotted config path into its segments. Segments containing
+// dots can be double-quoted: profiles."my.work".name. Everything after
+// git_configs is one git config key, so profiles.work.git_configs.commit.gpgsign
+// needs no quotes.
+func SplitPath(path string) ([]string, error) {
+	var segments []string
+	var current strings.Builder
+	quoted, wasQuoted := false, false
+
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+			wasQuoted = true
+		case c == '.' && !quoted:
+			if current.Len() == 0 && !wasQuoted {
+				return nil, &PathError{Path: path, Err: fmt.Errorf("empty segment")}
+			}
+			segments = append(segments, current.String())
+			current.Reset()
+			wasQuoted = false
+
+			// The rest of the path is the git config key, dots included
+			if len(segments) == 3 && segments[0] == "profiles" && segments[2] == "git_configs" {
+				rest := path[i+1:]
+				if unquoted, ok := strings.CutPrefix(rest, `"`); ok {
+					rest = strings.TrimSuffix(unquoted, `"`)
+				}
+				if rest == "" {
+					return nil, &PathError{Path: path, Err: fmt.Errorf("empty segment")}
+				}
+				return append(segments, rest), nil
+			}
+		default:
+			current.WriteByte(c)
+		}
+	}
+
+	if quoted {
+		return nil, &PathError{Path: path, Err: fmt.Errorf("unterminated quote")}
+	}
+	if current.Len() == 0 && !wasQuoted {
+		return nil, &PathError{Path: path, Err: fmt.Errorf("empty segment")}
+	}
+	return append(segments, current.String()), nil
+}
+
+// Get returns the value at a dotted path: a string for scalar settings, a
+// []string for lists and a map or Profile for whole sections
+func (c *Config) Get(path string) (interface{}, error) {
+	segments, err := SplitPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(segments) == 1 && segments[0] == "version":
+		return fmt.Sprint(c.Version), nil
+	case len(segments) == 1 && segments[0] == "profiles":
+		return c.Profiles, nil
+	case segments[0] != "profiles" || len(segments) == 1:
+		return nil, &PathError{Path: path, Err: fmt.Errorf("unknown setting, expected version or profiles.<name>...")}
+	}
+
+	profile, ok := c.Profiles[segments[1]]
+	if !ok {
+		return nil, &ProfileNotFoundError{Name: segments[1]}
+	}
+	if len(segments) == 2 {
+		return profile, nil
+	}
+
+	field, err := profileField(&profile, path, segments[2])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(segments) == 4 {
+		if field.Kind() != reflect.Map {
+			return nil, &PathError{Path: path, Err: fmt.Errorf("%s has no keys", segments[2])}
+		}
+		value, ok := profile.GitConfigs[segments[3]]
+		if !ok {
+			return nil, &NotSetError{Path: path}
+		}
+		return value, nil
+	}
+	if len(segments) > 4 {
+		return nil, &PathError{Path: path, Err: fmt.Errorf("unknown setting")}
+	}
+
+	if field.IsZero() {
+		return nil, &NotSetError{Path: path}
+	}
+	switch field.Kind() {
+	case reflect.Slice:
+		return field.Convert(reflect.TypeOf([]string{})).Interface(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(field.Bool()), nil
+	}
+	return field.Interface(), nil
+}
+
+// Set changes the setting at a dotted path. The value is parsed as YAML and
+// checked against the setting's type: lists accept a flow sequence such as
+// "[a, b]" or a single item. Profiles are created with 'gclone profile add',
+// so the profile must already exist.
+func (c *Config) Set(path, value string) error {
+	segments, err := SplitPath(path)
+	if err != nil {
+		return err
+	}
+	profile, field, err := c.profileSetting(path, segments)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(segments) == 4:
+		if !gitConfigKeyPattern.MatchString(segments[3]) {
+			return &PathError{Path: path, Err: fmt.Errorf("'%s' is not a valid git config key, expected section.name or section.subsection.name", segments[3])}
+		}
+		if profile.GitConfigs == nil {
+			profile.GitConfigs = make(map[string]string)
+		}
+		profile.GitConfigs[segments[3]] = value
+	case field.Kind() == reflect.Map:
+		var configs map[string]string
+		if err := yaml.Unmarshal([]byte(value), &configs); err != nil || configs == nil {
+			return &PathError{Path: path, Err: fmt.Errorf("expected a mapping such as '{user.name: Name}'")}
+		}
+		for key := range configs {
+			if !gitConfigKeyPattern.MatchString(key) {
+				return &PathError{Path: path, Err: fmt.Errorf("'%s' is not a valid git config key, expected section.name or section.subsection.name", key)}
+			}
+		}
+		field.Set(reflect.ValueOf(configs))
+	case field.Kind() == reflect.Slice:
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(value), &node); err != nil {
+			return &PathError{Path: path, Err: fmt.Errorf("invalid value: %w", err)}
+		}
+		var items []string
+		if len(node.Content) > 0 && node.Content[0].Kind == yaml.SequenceNode {
+			if err := node.Content[0].Decode(&items); err != nil {
+				return &PathError{Path: path, Err: fmt.Errorf("expected a list of strings")}
+			}
+		} else {
+			items = []string{value}
+		}
+		field.Set(reflect.ValueOf(items).Convert(field.Type()))
+	case field.Kind() == reflect.Bool:
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return &PathError{Path: path, Err: fmt.Errorf("expected true or false")}
+		}
+		field.SetBool(enabled)
+	default:
+		if err := checkEnum(segments[2], value); err != nil {
+			return &PathError{Path: path, Err: err}
+		}
+		field.SetString(value)
+	}
+
+	c.Profiles[segments[1]] = *profile
+	return nil
+}
+
+// Unset removes the setting at a dotted path. Unsetting profiles.<name>
+// removes the whole profile.
+func (c *Config) Unset(path string) error {
+	segments, err := SplitPath(path)
+	if err != nil {
+		return err
+	}
+
+	if len(segments) == 2 && segments[0] == "profiles" {
+		if _, ok := c.Profiles[segments[1]]; !ok {
+			return &ProfileNotFoundError{Name: segments[1]}
+		}
+		delete(c.Profiles, segments[1])
+		return nil
+	}
+
+	profile, field, err := c.profileSetting(path, segments)
+	if err != nil {
+		return err
+	}
+
+	if len(segments) == 4 {
+		if _, ok := profile.GitConfigs[segments[3]]; !ok {
+			return &NotSetError{Path: path}
+		}
+		delete(profile.GitConfigs, segments[3])
+	} else {
+		if field.IsZero() {
+			return &NotSetError{Path: path}
+		}
+		field.Set(reflect.Zero(field.Type()))
+		if field.Kind() == reflect.Map {
+			// LoadConfig never hands out nil git configs, keep it that way
+			field.Set(reflect.MakeMap(field.Type()))
+		}
+	}
+
+	c.Profiles[segments[1]] = *profile
+	return nil
+}
+
+// profileSetting resolves a path to a profile field that can be changed,
+// returning a copy of the profile and the field within that copy
+func (c *Config) profileSetting(path string, segments []string) (*Profile, reflect.Value, error) {
+	if len(segments) == 1 && segments[0] == "version" {
+		return nil, reflect.Value{}, &PathError{Path: path, Err: fmt.Errorf("the schema version is managed by gclone")}
+	}
+	if segments[0] != "profiles" || len(segments) < 3 {
+		return nil, reflect.Value{}, &PathError{Path: path, Err: fmt.Errorf("expected profiles.<name>.<setting>")}
+	}
+
+	existing, ok := c.Profiles[segments[1]]
+	if !ok {
+		return nil, reflect.Value{}, &ProfileNotFoundError{Name: segments[1]}
+	}
+	profile := existing
+
+	field, err := profileField(&profile, path, segments[2])
+	if err != nil {
+		return nil, reflect.Value{}, err
+	}
+	if len(segments) > 4 || (len(segments) == 4 && field.Kind() != reflect.Map) {
+		return nil, reflect.Value{}, &PathError{Path: path, Err: fmt.Errorf("unknown setting")}
+	}
+	return &profile, field, nil
+}
+
+// profileField returns the field of a profile with the given YAML key
+func profileField(profile *Profile, path, key string) (reflect.Value, error) {
+	v := reflect.ValueOf(profile).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if tag == key {
+			return v.Field(i), nil
+		}
+	}
+
+	keys := make([]string, 0, t.NumField())
+	for key := range profileKeys() {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return reflect.Value{}, &PathError{Path: path, Err: fmt.Errorf("unknown profile setting '%s', expected one of %s", key, strings.Join(keys, ", "))}
+}
+
+// checkEnum checks the value of settings that only accept some values
+func checkEnum(key, value string) error {
+	var allowed []string
+	switch key {
+	case "config_mode":
+		allowed = []string{ConfigModeCopy, ConfigModeInclude}
+	case "identity_guard":
+		allowed = []string{GuardPreCommit, GuardHooksPath}
+	default:
+		return nil
+	}
+
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value %q, expected %s", value, strings.Join(allowed, " or "))
+}
+
+// settingValue returns a profile field by its YAML key as text, lists in flow style
+func (p *Profile) settingValue(key string) (string, bool) {
+	field, err := profileField(p, key, key)
+	if err != nil || field.IsZero() {
+		return "", false
+	}
+	switch field.Kind() {
+	case reflect.Slice:
+		return "[" + strings.Join(field.Convert(reflect.TypeOf([]string{})).Interface().([]string), ", ") + "]", true
+	case reflect.Bool:
+		return strconv.FormatBool(field.Bool()), true
+	case reflect.String:
+		return field.String(), true
+	}
+	return fmt.Sprint(field.Interface()), true
+}
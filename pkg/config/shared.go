@@ -0,0 +1,236 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/user-cube/gclone/pkg/fileutil"
+	"gopkg.in/yaml.v3"
+)
+
+// Subscription is a git repository of shared profiles, kept in SharedDir and
+// merged into the configuration as read-only layers
+type Subscription struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	// Path is the directory of the repository holding the profile files, the top by default
+	Path string `yaml:"path,omitempty"`
+}
+
+// subscriptionsFile is the format of SubscriptionsFile
+type subscriptionsFile struct {
+	Subscriptions []Subscription `yaml:"subscriptions"`
+}
+
+// SharedDir returns the directory holding the subscribed repositories
+func SharedDir() string {
+	return filepath.Join(DefaultConfigDir(), "shared")
+}
+
+// SubscriptionsFile returns the file listing the subscribed repositories
+func SubscriptionsFile() string {
+	return filepath.Join(SharedDir(), "subscriptions.yml")
+}
+
+// Dir returns the directory the subscribed repository is cloned to
+func (s *Subscription) Dir() string {
+	return filepath.Join(SharedDir(), s.Name)
+}
+
+// Files returns the profile files of the subscription in name order: the
+// .yml files in its Path, leaving out hidden ones such as CI configs
+func (s *Subscription) Files() []string {
+	return s.filesIn(s.Dir())
+}
+
+// filesIn returns the profile files of the subscription in a checkout of its repository
+func (s *Subscription) filesIn(dir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(dir, s.Path, "*.yml"))
+	files := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if !strings.HasPrefix(filepath.Base(match), ".") {
+			files = append(files, match)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Check loads and validates the profile files of the subscription in a
+// checkout of its repository at dir, its Dir when empty, so a broken commit
+// is never merged into the configuration. Problems are reported against the
+// files' paths in the repository, prefixed with the subscription name.
+func (s *Subscription) Check(dir string) error {
+	if dir == "" {
+		dir = s.Dir()
+	}
+	display := func(file string) string {
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return file
+		}
+		return filepath.Join(s.Name, rel)
+	}
+
+	files := s.filesIn(dir)
+	if _, err := LoadFiles(files); err != nil {
+		var configErr *ConfigError
+		if errors.As(err, &configErr) {
+			return &ConfigError{Path: display(configErr.Path), Err: configErr.Err}
+		}
+		return err
+	}
+	diagnostics, err := ValidateFiles(files, nil)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			d.File = display(d.File)
+			problems = append(problems, d.String())
+		}
+	}
+	if len(problems) > 0 {
+		return &ConfigError{Path: s.URL, Err: fmt.Errorf("shared profiles have errors:\n  %s", strings.Join(problems, "\n  "))}
+	}
+	return nil
+}
+
+// LoadSubscriptions returns the subscribed repositories in name order
+func LoadSubscriptions() ([]Subscription, error) {
+	file := SubscriptionsFile()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, &ConfigError{Path: file, Err: fmt.Errorf("error reading subscriptions: %w", err)}
+	}
+
+	var parsed subscriptionsFile
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return nil, &ConfigError{Path: file, Err: fmt.Errorf("error parsing subscriptions: %w", err)}
+	}
+
+	sort.Slice(parsed.Subscriptions, func(i, j int) bool {
+		return parsed.Subscriptions[i].Name < parsed.Subscriptions[j].Name
+	})
+	return parsed.Subscriptions, nil
+}
+
+// SaveSubscriptions writes the list of subscribed repositories
+func SaveSubscriptions(subscriptions []Subscription) error {
+	file := SubscriptionsFile()
+	data, err := yaml.Marshal(subscriptionsFile{Subscriptions: subscriptions})
+	if err != nil {
+		return &ConfigError{Path: file, Err: fmt.Errorf("error encoding subscriptions: %w", err)}
+	}
+	if err := fileutil.WriteFileAtomic(file, data, 0644); err != nil {
+		return &ConfigError{Path: file, Err: err}
+	}
+	return nil
+}
+
+// sharedFiles returns the profile files of every subscription, in the order they are merged
+func sharedFiles() []string {
+	subscriptions, err := LoadSubscriptions()
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, s := range subscriptions {
+		files = append(files, s.Files()...)
+	}
+	return files
+}
+
+// LoadFiles merges config files like layers, lowest first, without resolving
+// inheritance. Files that do not exist are skipped and nothing is written.
+func LoadFiles(files []string) (*Config, error) {
+	merged := &Config{
+		Version:  SchemaVersion,
+		Profiles: make(map[string]Profile),
+		origins:  make(map[originKey]string),
+	}
+	for _, file := range files {
+		layer, err := loadFile(file, false)
+		if err != nil {
+			return nil, err
+		}
+		merged.overlay(file, layer)
+	}
+	return merged, nil
+}
+
+// Change is a setting that differs between two sets of profiles. Old is empty
+// for added settings and New for removed ones.
+type Change struct {
+	Profile string
+	Setting string
+	Old     string
+	New     string
+}
+
+// DiffProfiles returns the settings that differ from before to after, ordered
+// by profile and setting. Settings are named as in Config.Origin.
+func DiffProfiles(before, after map[string]Profile) []Change {
+	var changes []Change
+	names := make(map[string]bool)
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+
+	for name := range names {
+		oldProfile, newProfile := before[name], after[name]
+		oldSettings, newSettings := flattenProfile(&oldProfile), flattenProfile(&newProfile)
+		for setting, oldValue := range oldSettings {
+			if newValue, ok := newSettings[setting]; !ok || newValue != oldValue {
+				changes = append(changes, Change{Profile: name, Setting: setting, Old: oldValue, New: newValue})
+			}
+		}
+		for setting, newValue := range newSettings {
+			if _, ok := oldSettings[setting]; !ok {
+				changes = append(changes, Change{Profile: name, Setting: setting, New: newValue})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Profile != changes[j].Profile {
+			return changes[i].Profile < changes[j].Profile
+		}
+		return changes[i].Setting < changes[j].Setting
+	})
+	return changes
+}
+
+// flattenProfile lists the settings of a profile with their values as text
+func flattenProfile(profile *Profile) map[string]string {
+	settings := make(map[string]string)
+	mergeProfile(&Profile{}, profile, func(setting string) {
+		settings[setting] = ""
+	})
+
+	for setting := range settings {
+		key, sub, _ := strings.Cut(setting, ".")
+		switch key {
+		case "git_configs":
+			settings[setting] = profile.GitConfigs[sub]
+		case "url_patterns":
+			settings[setting] = sub
+		default:
+			value, _ := profile.settingValue(key)
+			settings[setting] = value
+		}
+	}
+	return settings
+}
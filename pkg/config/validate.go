@@ -0,0 +1,466 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/user-cube/gclone/pkg/sshconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// Severities of validation diagnostics
+const (
+	// SeverityError marks problems that break cloning or applying the profile
+	SeverityError = "error"
+	// SeverityWarning marks settings that work but are probably not what was meant
+	SeverityWarning = "warning"
+)
+
+// Diagnostic is a problem found in a config file
+type Diagnostic struct {
+	File     string `json:"file"`
+	Severity string `json:"severity"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	// Profile is the profile the problem belongs to, empty for the file as a whole
+	Profile string `json:"profile,omitempty"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Profile != "" {
+		return fmt.Sprintf("%s:%d:%d: %s: profile '%s': %s", d.File, d.Line, d.Column, d.Severity, d.Profile, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Severity, d.Message)
+}
+
+// gitConfigKeyPattern matches section.name and section.subsection.name keys.
+// Subsections may contain anything but newlines, names must start with a letter.
+var gitConfigKeyPattern = regexp.MustCompile(`^[A-Za-z0-9-]+(\..+)?\.[A-Za-z][A-Za-z0-9-]*$`)
+
+// knownGitSections are the config sections documented by git-config(1), in
+// lower case. Keys of other sections are reported as warnings since git
+// ignores settings it does not know.
+var knownGitSections = map[string]bool{
+	"add": true, "advice": true, "alias": true, "am": true, "apply": true,
+	"author": true, "blame": true, "branch": true, "browser": true, "bundle": true,
+	"checkout": true, "clean": true, "clone": true, "color": true, "column": true,
+	"commit": true, "commitgraph": true, "committer": true, "completion": true,
+	"core": true, "credential": true, "diff": true, "difftool": true,
+	"extensions": true, "fastimport": true, "feature": true, "fetch": true,
+	"filter": true, "format": true, "fsck": true, "fsmonitor": true, "gc": true,
+	"gitcvs": true, "gitweb": true, "gpg": true, "grep": true, "gui": true,
+	"guitool": true, "help": true, "http": true, "i18n": true, "imap": true,
+	"include": true, "includeif": true, "index": true, "init": true,
+	"instaweb": true, "interactive": true, "log": true, "lsrefs": true,
+	"mailinfo": true, "mailmap": true, "maintenance": true, "man": true,
+	"merge": true, "mergetool": true, "notes": true, "pack": true, "pager": true,
+	"pretty": true, "protocol": true, "pull": true, "push": true, "rebase": true,
+	"receive": true, "remote": true, "remotes": true, "repack": true,
+	"rerere": true, "revert": true, "safe": true, "sendemail": true,
+	"sequence": true, "showbranch": true, "sparse": true, "splitindex": true,
+	"ssh": true, "stash": true, "status": true, "submodule": true, "tag": true,
+	"tar": true, "trace2": true, "trailer": true, "transfer": true,
+	"uploadarchive": true, "uploadpack": true, "url": true, "user": true,
+	"versionsort": true, "web": true, "worktree": true,
+	// Common settings of widely used extensions
+	"lfs": true,
+}
+
+// Validate checks every layer of the configuration (see LayerFiles) and
+// returns the problems in layer order, then by position. Settings are checked
+// as they will be used, after merging the layers and resolving inheritance.
+// SSH host aliases are looked up in hosts, the check is skipped when it is nil.
+// Only files that cannot be read or parsed return an error.
+func Validate(configFile string, hosts *sshconfig.Config) ([]Diagnostic, error) {
+	if configFile == "" {
+		configFile = DefaultConfigFile()
+	}
+
+	files := ExistingLayerFiles(configFile)
+	if len(files) == 0 {
+		files = []string{configFile}
+	}
+	return ValidateFiles(files, hosts)
+}
+
+// ValidateFiles checks config files as layers merged in the given order, the
+// way Validate checks the layers of the configuration
+func ValidateFiles(files []string, hosts *sshconfig.Config) ([]Diagnostic, error) {
+	v := &validator{hosts: hosts, lastLayer: make(map[string]int)}
+	raw := &Config{Profiles: make(map[string]Profile), origins: make(map[originKey]string)}
+	for i, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, &ConfigError{Path: file, Err: fmt.Errorf("error reading config file: %w", err)}
+		}
+
+		root := &yaml.Node{}
+		if err := yaml.Unmarshal(data, root); err != nil {
+			return nil, &ConfigError{Path: file, Err: fmt.Errorf("error parsing config file: %w", err)}
+		}
+		v.roots = append(v.roots, root)
+
+		// Settings can be inherited from other profiles and layers, so the
+		// profiles are also checked as they will be used
+		if profiles := mappingValue(root, "profiles"); profiles != nil && profiles.Kind == yaml.MappingNode {
+			layer := &Config{Profiles: make(map[string]Profile)}
+			for j := 0; j+1 < len(profiles.Content); j += 2 {
+				var profile Profile
+				if ValidateProfileName(profiles.Content[j].Value) == nil && profiles.Content[j+1].Decode(&profile) == nil {
+					layer.Profiles[profiles.Content[j].Value] = profile
+					v.lastLayer[profiles.Content[j].Value] = i
+				}
+			}
+			raw.overlay(file, layer)
+		}
+	}
+	v.resolver = newResolver(raw.Profiles)
+
+	var patterns []urlPattern
+	for i, root := range v.roots {
+		v.file, v.layer = files[i], i
+		patterns = append(patterns, v.document(root)...)
+	}
+	v.overlappingPatterns(patterns)
+
+	sort.SliceStable(v.diagnostics, func(i, j int) bool {
+		a, b := v.diagnostics[i], v.diagnostics[j]
+		if a.File != b.File {
+			return indexOf(files, a.File) < indexOf(files, b.File)
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return v.diagnostics, nil
+}
+
+// HasErrors reports whether any diagnostic is an error
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// validator collects the diagnostics of the config layers
+type validator struct {
+	hosts    *sshconfig.Config
+	resolver *resolver
+	roots    []*yaml.Node
+	// lastLayer is the highest layer defining each profile, where the
+	// profile's merged settings are checked
+	lastLayer map[string]int
+	// file and layer are the document being checked
+	file        string
+	layer       int
+	diagnostics []Diagnostic
+}
+
+// urlPattern is a url_patterns entry with the profile and file it belongs to
+type urlPattern struct {
+	profile string
+	file    string
+	node    *yaml.Node
+	// value is the pattern with its ${...} references expanded
+	value string
+}
+
+func (v *validator) report(severity string, node *yaml.Node, profile, format string, args ...interface{}) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{
+		File:     v.file,
+		Severity: severity,
+		Line:     node.Line,
+		Column:   node.Column,
+		Profile:  profile,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// document checks one layer and returns its URL patterns for the checks across profiles
+func (v *validator) document(root *yaml.Node) []urlPattern {
+	mapping := documentMapping(root)
+	if mapping == nil {
+		if root.Kind != 0 {
+			v.report(SeverityError, root, "", "config file must be a mapping with a 'profiles' key")
+		}
+		return nil
+	}
+
+	if version := mappingValue(root, "version"); version != nil {
+		if n, err := documentVersion(root); err != nil {
+			v.report(SeverityError, version, "", "%v, expected a whole number", err)
+		} else if n > SchemaVersion {
+			v.report(SeverityError, version, "", "schema version %d is newer than this gclone supports (%d)", n, SchemaVersion)
+		}
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key := mapping.Content[i]
+		if key.Value != "version" && key.Value != "profiles" {
+			v.report(SeverityWarning, key, "", "unknown key '%s' is ignored", key.Value)
+		}
+	}
+
+	profiles := mappingValue(root, "profiles")
+	if profiles == nil {
+		return nil
+	}
+	if profiles.Kind != yaml.MappingNode {
+		v.report(SeverityError, profiles, "", "'profiles' must be a mapping of profile names to settings")
+		return nil
+	}
+
+	var patterns []urlPattern
+	for i := 0; i+1 < len(profiles.Content); i += 2 {
+		name, body := profiles.Content[i], profiles.Content[i+1]
+		patterns = append(patterns, v.profile(name, body)...)
+	}
+	return patterns
+}
+
+// profile checks one profile and returns its URL patterns for the cross-profile checks
+func (v *validator) profile(nameNode, body *yaml.Node) []urlPattern {
+	name := nameNode.Value
+	if err := ValidateProfileName(name); err != nil {
+		v.report(SeverityError, nameNode, name, "%v, the profile is ignored", err)
+		return nil
+	}
+	if body.Kind != yaml.MappingNode {
+		v.report(SeverityError, body, name, "profile settings must be a mapping")
+		return nil
+	}
+
+	var profile Profile
+	if err := body.Decode(&profile); err != nil {
+		v.report(SeverityError, body, name, "%v", err)
+		return nil
+	}
+
+	known := profileKeys()
+	for i := 0; i+1 < len(body.Content); i += 2 {
+		if key := body.Content[i]; !known[key.Value] {
+			v.report(SeverityWarning, key, name, "unknown key '%s' is ignored", key.Value)
+		}
+	}
+
+	field := func(key string) *yaml.Node {
+		for i := 0; i+1 < len(body.Content); i += 2 {
+			if body.Content[i].Value == key {
+				return body.Content[i+1]
+			}
+		}
+		return nameNode
+	}
+
+	// The merged settings are checked once, in the highest layer defining the profile
+	if v.layer == v.lastLayer[name] {
+		v.mergedProfile(name, field)
+	}
+
+	if configs := field("git_configs"); configs.Kind == yaml.MappingNode {
+		v.gitConfigs(name, configs)
+	}
+
+	if resolved, err := v.resolver.resolve(name); err == nil && resolved.Abstract {
+		// Abstract profiles are never used on their own
+		return nil
+	}
+
+	var patterns []urlPattern
+	if list := field("url_patterns"); list.Kind == yaml.SequenceNode {
+		seen := make(map[string]bool)
+		for _, item := range list.Content {
+			value, _, err := Interpolate(item.Value, filepath.Dir(v.file))
+			switch {
+			case err != nil:
+				v.report(SeverityError, item, name, "url_patterns: %v", err)
+				continue
+			case strings.TrimSpace(value) == "":
+				v.report(SeverityError, item, name, "empty URL pattern matches every repository")
+				continue
+			case seen[value]:
+				v.report(SeverityWarning, item, name, "URL pattern '%s' is listed twice", value)
+				continue
+			}
+			seen[value] = true
+			patterns = append(patterns, urlPattern{profile: name, file: v.file, node: item, value: value})
+		}
+	}
+	return patterns
+}
+
+// mergedProfile checks a profile as it will be used, merged across layers and
+// with inheritance resolved. field returns the node of a setting in the
+// current layer, used as the position of the problems found.
+func (v *validator) mergedProfile(name string, field func(string) *yaml.Node) {
+	profile, err := v.resolver.resolve(name)
+	if err != nil {
+		var inheritanceErr *InheritanceError
+		if errors.As(err, &inheritanceErr) && inheritanceErr.Profile == name {
+			err = inheritanceErr.Err
+		}
+		v.report(SeverityError, field("extends"), name, "%v", err)
+		return
+	}
+	if profile.Abstract {
+		return
+	}
+
+	v.sshHost(name, &profile, field)
+
+	if profile.IdentityFile != "" {
+		path := ExpandHome(profile.IdentityFile)
+		if info, err := os.Stat(path); err != nil {
+			v.report(SeverityError, field("identity_file"), name, "identity file %s does not exist", profile.IdentityFile)
+		} else if info.IsDir() {
+			v.report(SeverityError, field("identity_file"), name, "identity file %s is a directory", profile.IdentityFile)
+		}
+	}
+
+	if profile.ConfigMode != "" && profile.ConfigMode != ConfigModeCopy && profile.ConfigMode != ConfigModeInclude {
+		v.report(SeverityError, field("config_mode"), name, "config_mode must be %s or %s, not '%s'", ConfigModeCopy, ConfigModeInclude, profile.ConfigMode)
+	}
+	if profile.IdentityGuard != "" && profile.IdentityGuard != GuardPreCommit && profile.IdentityGuard != GuardHooksPath {
+		v.report(SeverityError, field("identity_guard"), name, "identity_guard must be %s or %s, not '%s'", GuardPreCommit, GuardHooksPath, profile.IdentityGuard)
+	}
+}
+
+// sshHost checks that the profile has a way to reach the server with its own key
+func (v *validator) sshHost(name string, profile *Profile, field func(string) *yaml.Node) {
+	if profile.SSHHost == "" {
+		// Profiles with an identity file keep the original host and pick the key through core.sshCommand
+		if profile.IdentityFile == "" {
+			v.report(SeverityError, field("ssh_host"), name, "no ssh_host set, add one (see 'gclone ssh-config') or set identity_file")
+		}
+		return
+	}
+	host, secret, err := Interpolate(profile.SSHHost, filepath.Dir(v.file))
+	if err != nil {
+		v.report(SeverityError, field("ssh_host"), name, "ssh_host: %v", err)
+		return
+	}
+	if profile.IdentityFile != "" || v.hosts == nil {
+		return
+	}
+	if !v.hosts.HasHost(host) {
+		if secret {
+			host = MaskedValue
+		}
+		v.report(SeverityWarning, field("ssh_host"), name, "ssh_host %s has no Host block in ~/.ssh/config or ~/.gclone/ssh_config, run 'gclone ssh-config' to add one", host)
+	}
+}
+
+// gitConfigs checks the keys and the user.email of a git_configs mapping
+func (v *validator) gitConfigs(name string, configs *yaml.Node) {
+	for i := 0; i+1 < len(configs.Content); i += 2 {
+		key, value := configs.Content[i], configs.Content[i+1]
+
+		if !gitConfigKeyPattern.MatchString(key.Value) {
+			v.report(SeverityError, key, name, "'%s' is not a valid git config key, expected section.name or section.subsection.name", key.Value)
+			continue
+		}
+		section := strings.ToLower(key.Value[:strings.IndexByte(key.Value, '.')])
+		if !knownGitSections[section] {
+			v.report(SeverityWarning, key, name, "unknown git config section in '%s', git will ignore it", key.Value)
+		}
+
+		email, secret, err := Interpolate(value.Value, filepath.Dir(v.file))
+		if err != nil {
+			v.report(SeverityError, value, name, "git_configs.%s: %v", key.Value, err)
+			continue
+		}
+		if strings.EqualFold(key.Value, "user.email") {
+			if address, err := mail.ParseAddress(email); err != nil || address.Name != "" || address.Address != email {
+				if secret {
+					email = MaskedValue
+				}
+				v.report(SeverityError, value, name, "user.email '%s' is not a valid email address", email)
+			}
+		}
+	}
+}
+
+// overlappingPatterns reports URL patterns that match the same repositories.
+// Patterns match by substring, so one containing another overlaps with it.
+func (v *validator) overlappingPatterns(patterns []urlPattern) {
+	for i, a := range patterns {
+		for _, b := range patterns[i+1:] {
+			switch {
+			case a.profile == b.profile:
+				// Patterns of one profile may overlap freely, duplicates were reported already
+			case a.value == b.value:
+				v.file = b.file
+				v.report(SeverityError, b.node, b.profile, "URL pattern '%s' is also used by profile '%s' (%s), only one of them can match",
+					b.value, a.profile, position(a, b.file))
+			case strings.Contains(a.value, b.value) || strings.Contains(b.value, a.value):
+				v.file = b.file
+				v.report(SeverityWarning, b.node, b.profile, "URL pattern '%s' overlaps '%s' of profile '%s' (%s), repositories matching both go to '%s'",
+					b.value, a.value, a.profile, position(a, b.file), firstProfile(a.profile, b.profile))
+			}
+		}
+	}
+}
+
+// position describes where a URL pattern is, leaving out the file when it is
+// the one being reported on
+func position(p urlPattern, file string) string {
+	if p.file == file {
+		return fmt.Sprintf("line %d", p.node.Line)
+	}
+	return fmt.Sprintf("%s:%d", p.file, p.node.Line)
+}
+
+// indexOf returns the position of item in list, or -1
+func indexOf(list []string, item string) int {
+	for i, existing := range list {
+		if existing == item {
+			return i
+		}
+	}
+	return -1
+}
+
+// firstProfile returns the profile checked first by profile detection, which
+// goes through profiles in alphabetical order
+func firstProfile(a, b string) string {
+	if b < a {
+		return b
+	}
+	return a
+}
+
+// profileKeys returns the YAML keys of the Profile fields
+func profileKeys() map[string]bool {
+	keys := make(map[string]bool)
+	t := reflect.TypeOf(Profile{})
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if tag != "" && tag != "-" {
+			keys[tag] = true
+		}
+	}
+	return keys
+}
+
+// ExpandHome replaces a leading ~ with the home directory. The path is
+// returned unchanged when the home directory is unknown.
+func ExpandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
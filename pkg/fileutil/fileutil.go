@@ -0,0 +1,153 @@
+// Package fileutil provides crash- and concurrency-safe file helpers.
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// WriteFileAtomic writes data to a temporary file next to path, syncs it to
+// disk and renames it over path, so readers never see a partially written file
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		// Only left behind if something failed before the rename
+		_ = os.Remove(tmpName)
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("error writing temporary file: %w", err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("error setting file permissions: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("error syncing temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("error replacing %s: %w", path, err)
+	}
+
+	// Make the rename itself durable
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+
+	return nil
+}
+
+// Lock is an advisory lock held on a lock file
+type Lock struct {
+	file *os.File
+	path string
+}
+
+// held records the lock files this process holds, see TryLockFile
+var (
+	heldMu sync.Mutex
+	held   = make(map[string]bool)
+)
+
+// LockFile takes an exclusive advisory lock on path+".lock", waiting for other
+// holders to release it. The lock is released by Unlock or when the process exits.
+func LockFile(path string) (*Lock, error) {
+	file, err := openLockFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockExclusive(file); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("error locking %s: %w", path, err)
+	}
+
+	return newLock(file), nil
+}
+
+// TryLockFile takes the lock of LockFile without waiting; ok is false when
+// another process holds it. When this process holds it already, the returned
+// lock does nothing, as the caller runs under the existing one.
+func TryLockFile(path string) (lock *Lock, ok bool, err error) {
+	file, err := openLockFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	heldMu.Lock()
+	mine := held[file.Name()]
+	heldMu.Unlock()
+	if mine {
+		_ = file.Close()
+		return &Lock{}, true, nil
+	}
+
+	locked, err := tryLockExclusive(file)
+	if err != nil || !locked {
+		_ = file.Close()
+		if err != nil {
+			return nil, false, fmt.Errorf("error locking %s: %w", path, err)
+		}
+		return nil, false, nil
+	}
+	return newLock(file), true, nil
+}
+
+// openLockFile opens the lock file of path, creating it when missing
+func openLockFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("error creating directory: %w", err)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	file, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening lock file: %w", err)
+	}
+	return file, nil
+}
+
+// newLock records a lock taken on file as held by this process
+func newLock(file *os.File) *Lock {
+	heldMu.Lock()
+	held[file.Name()] = true
+	heldMu.Unlock()
+	return &Lock{file: file, path: file.Name()}
+}
+
+// Unlock releases the lock
+func (l *Lock) Unlock() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	heldMu.Lock()
+	delete(held, l.path)
+	heldMu.Unlock()
+
+	err := unlock(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package fileutil
+
+import "os"
+
+// lockExclusive is a no-op: advisory locking is only implemented on unix
+// platforms, which are the only ones gclone is released for
+func lockExclusive(file *os.File) error {
+	return nil
+}
+
+// tryLockExclusive is a no-op, see lockExclusive
+func tryLockExclusive(file *os.File) (bool, error) {
+	return true, nil
+}
+
+// unlock is a no-op, see lockExclusive
+func unlock(file *os.File) error {
+	return nil
+}
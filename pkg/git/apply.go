@@ -0,0 +1,221 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/ui"
+)
+
+// RemoteChange describes a remote URL rewritten to go through a profile's SSH host
+type RemoteChange struct {
+	Name   string
+	OldURL string
+	NewURL string
+}
+
+// ConfigChange describes a local git config value set from a profile.
+// OldValue is empty when the key was not set before.
+type ConfigChange struct {
+	Key      string
+	OldValue string
+	NewValue string
+	WasSet   bool
+}
+
+// ApplyPlan lists the changes needed for an existing repository to follow a profile
+type ApplyPlan struct {
+	RepoPath    string
+	ProfileName string
+	Remotes     []RemoteChange
+	Configs     []ConfigChange
+	// Removals lists keys gclone set earlier that the profile no longer defines
+	Removals []ConfigChange
+	// Skipped lists remotes whose URL format cannot be transformed
+	Skipped []Remote
+	// AddInclude is the generated profile file to link through include.path, if not linked yet
+	AddInclude string
+	// RemoveIncludes lists include.path entries of other generated profile files
+	RemoveIncludes []string
+	// InstallGuard is the identity guard mode to install, if the profile wants one and it is missing
+	InstallGuard string
+	// ConfigFile is the gclone config file the identity guard reads, empty for the default
+	ConfigFile string
+	// RecordOutdated is set when gclone's own bookkeeping in the repository needs updating
+	RecordOutdated bool
+
+	profile     *config.Profile
+	managedKeys []string
+}
+
+// PlanApply compares a repository with a profile and works out which remotes
+// and local git configs have to change. Nothing is modified.
+func PlanApply(repoPath, profileName string, profile *config.Profile) (*ApplyPlan, error) {
+	plan := &ApplyPlan{RepoPath: repoPath, ProfileName: profileName, profile: profile}
+
+	remotes, err := ListRemotes(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading remotes: %w", err)
+	}
+
+	for _, remote := range remotes {
+		newURL, err := TransformGitURL(remote.URL, profile)
+		if err != nil {
+			var urlErr *UnsupportedURLError
+			if errors.As(err, &urlErr) {
+				plan.Skipped = append(plan.Skipped, remote)
+				continue
+			}
+			return nil, err
+		}
+		if newURL != remote.URL {
+			plan.Remotes = append(plan.Remotes, RemoteChange{Name: remote.Name, OldURL: remote.URL, NewURL: newURL})
+		}
+	}
+
+	profileConfigs := ProfileGitConfigs(profile)
+	keys := make([]string, 0, len(profileConfigs))
+	for key := range profileConfigs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Keys that must not be set locally: gclone's earlier copies that the profile
+	// dropped, and in include mode every copy, since it would shadow the include
+	var unmanaged []string
+	if profile.UsesInclude() {
+		unmanaged = keys
+		keys = nil
+	}
+
+	for _, key := range keys {
+		value := profileConfigs[key]
+		current, isSet, err := GetLocalConfig(repoPath, key)
+		if err != nil {
+			return nil, fmt.Errorf("error reading git config %s: %w", key, err)
+		}
+		if !isSet || current != value {
+			plan.Configs = append(plan.Configs, ConfigChange{Key: key, OldValue: current, NewValue: value, WasSet: isSet})
+		}
+	}
+	plan.managedKeys = keys
+
+	includes, err := ListIncludes(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading include.path: %w", err)
+	}
+	wantInclude := ""
+	if profile.UsesInclude() {
+		wantInclude = ProfileConfigFile(profileName)
+		plan.AddInclude = wantInclude
+	}
+	for _, include := range includes {
+		if include == wantInclude {
+			plan.AddInclude = ""
+		} else if isProfileConfigFile(include) {
+			plan.RemoveIncludes = append(plan.RemoveIncludes, include)
+		}
+	}
+
+	if profile.IdentityGuard != "" {
+		installed, err := GuardInstalled(repoPath)
+		if err != nil {
+			return nil, fmt.Errorf("error checking identity guard: %w", err)
+		}
+		if !installed {
+			plan.InstallGuard = profile.IdentityGuard
+		}
+	}
+
+	recordedProfile, recordedKeys, err := ReadProfileRecord(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading gclone record: %w", err)
+	}
+	for _, key := range recordedKeys {
+		if _, kept := profileConfigs[key]; !kept {
+			unmanaged = append(unmanaged, key)
+		}
+	}
+	for _, key := range unmanaged {
+		current, isSet, err := GetLocalConfig(repoPath, key)
+		if err != nil {
+			return nil, fmt.Errorf("error reading git config %s: %w", key, err)
+		}
+		if isSet {
+			plan.Removals = append(plan.Removals, ConfigChange{Key: key, OldValue: current, WasSet: true})
+		}
+	}
+	plan.RecordOutdated = recordedProfile != profileName || strings.Join(recordedKeys, "\n") != strings.Join(keys, "\n")
+
+	return plan, nil
+}
+
+// IsEmpty reports whether the repository already follows the profile
+func (p *ApplyPlan) IsEmpty() bool {
+	return len(p.Remotes) == 0 && len(p.Configs) == 0 && len(p.Removals) == 0 &&
+		p.AddInclude == "" && len(p.RemoveIncludes) == 0 && p.InstallGuard == ""
+}
+
+// Execute rewrites the remotes and sets the git configs listed in the plan
+func (p *ApplyPlan) Execute() error {
+	for _, change := range p.Remotes {
+		if err := SetRemoteURL(p.RepoPath, change.Name, change.NewURL); err != nil {
+			return fmt.Errorf("failed to rewrite remote %s: %w", change.Name, err)
+		}
+	}
+
+	if len(p.Configs) > 0 {
+		configs := make(map[string]string, len(p.Configs))
+		for _, change := range p.Configs {
+			configs[change.Key] = change.NewValue
+		}
+		if err := ApplyGitConfigs(p.RepoPath, configs); err != nil {
+			return fmt.Errorf("failed to apply git configs: %w", err)
+		}
+	}
+
+	for _, change := range p.Removals {
+		ui.Info("Removing git config %s\n", change.Key)
+		if err := UnsetLocalConfig(p.RepoPath, change.Key); err != nil {
+			return fmt.Errorf("failed to remove git config %s: %w", change.Key, err)
+		}
+	}
+
+	for _, include := range p.RemoveIncludes {
+		ui.Info("Removing include.path %s\n", include)
+		if err := RemoveInclude(p.RepoPath, include); err != nil {
+			return fmt.Errorf("failed to remove include.path %s: %w", include, err)
+		}
+	}
+
+	if p.profile.UsesInclude() {
+		// Regenerate the file even when already linked, the profile may have been edited by hand
+		if err := WriteProfileConfigFile(p.ProfileName, p.profile); err != nil {
+			return err
+		}
+	}
+	if p.AddInclude != "" {
+		ui.Info("Adding include.path %s\n", p.AddInclude)
+		if err := AddInclude(p.RepoPath, p.AddInclude); err != nil {
+			return fmt.Errorf("failed to add include.path: %w", err)
+		}
+	}
+
+	if p.InstallGuard != "" {
+		ui.Info("Installing identity guard (%s)\n", p.InstallGuard)
+		if err := InstallGuard(p.RepoPath, p.InstallGuard, p.ConfigFile); err != nil {
+			return fmt.Errorf("failed to install identity guard: %w", err)
+		}
+	}
+
+	if p.RecordOutdated || !p.IsEmpty() {
+		if err := WriteProfileRecord(p.RepoPath, p.ProfileName, p.managedKeys); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
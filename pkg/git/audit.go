@@ -0,0 +1,166 @@
+package git
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/user-cube/gclone/pkg/config"
+)
+
+// Kinds of problems reported by AuditRepository
+const (
+	// AuditNoProfile means no profile matches the repository's remote
+	AuditNoProfile = "no-profile"
+	// AuditRemote means a remote bypasses the profile's SSH host
+	AuditRemote = "remote"
+	// AuditConfig means a local git config differs from the profile
+	AuditConfig = "config"
+	// AuditStaleConfig means a git config set by gclone is no longer in the profile
+	AuditStaleConfig = "stale-config"
+	// AuditInclude means the include.path of the profile's generated config file is missing or wrong
+	AuditInclude = "include"
+	// AuditGuard means the profile's identity guard hook is not installed
+	AuditGuard = "guard"
+	// AuditProfileError means the profile cannot be used, so the repository was not compared
+	AuditProfileError = "profile-error"
+)
+
+// AuditIssue is a single mismatch between a repository and its profile
+type AuditIssue struct {
+	Kind     string `json:"kind"`
+	Subject  string `json:"subject"`
+	Actual   string `json:"actual"`
+	Expected string `json:"expected"`
+}
+
+// AuditResult holds the findings for one repository
+type AuditResult struct {
+	Path    string       `json:"path"`
+	Remote  string       `json:"remote"`
+	Profile string       `json:"profile"`
+	Issues  []AuditIssue `json:"issues"`
+	// Plan fixes every issue that can be fixed, nil when no profile matched
+	Plan *ApplyPlan `json:"-"`
+}
+
+// FindRepositories walks root and returns the top-level directory of every git
+// repository below it. Repositories nested inside another one are not reported.
+func FindRepositories(root string) ([]string, error) {
+	var repos []string
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable directories are skipped rather than aborting the whole walk
+			if path != root && os.IsPermission(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		// .git is a directory for regular clones and a file for worktrees and submodules
+		if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+			repos = append(repos, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning %s: %w", root, err)
+	}
+
+	return repos, nil
+}
+
+// AuditRepository works out the expected profile of a repository from its origin
+// remote (or first remote) and compares the repository against it
+func AuditRepository(repoPath string, profiles map[string]config.Profile) (*AuditResult, error) {
+	result := &AuditResult{Path: repoPath, Issues: []AuditIssue{}}
+
+	remotes, err := ListRemotes(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading remotes of %s: %w", repoPath, err)
+	}
+	for _, remote := range remotes {
+		if result.Remote == "" || remote.Name == "origin" {
+			result.Remote = remote.URL
+		}
+	}
+
+	profileName, found := DetectProfileForURL(result.Remote, profiles)
+	if result.Remote == "" || !found {
+		result.Issues = append(result.Issues, AuditIssue{Kind: AuditNoProfile, Subject: "remote", Actual: result.Remote})
+		return result, nil
+	}
+	result.Profile = profileName
+
+	profile := profiles[profileName]
+	plan, err := PlanApply(repoPath, profileName, &profile)
+	if err != nil {
+		return nil, fmt.Errorf("error auditing %s: %w", repoPath, err)
+	}
+	result.Plan = plan
+
+	for _, change := range plan.Remotes {
+		result.Issues = append(result.Issues, AuditIssue{
+			Kind:     AuditRemote,
+			Subject:  change.Name,
+			Actual:   change.OldURL,
+			Expected: change.NewURL,
+		})
+	}
+	for _, change := range plan.Configs {
+		result.Issues = append(result.Issues, AuditIssue{
+			Kind:     AuditConfig,
+			Subject:  change.Key,
+			Actual:   change.OldValue,
+			Expected: change.NewValue,
+		})
+	}
+	for _, change := range plan.Removals {
+		result.Issues = append(result.Issues, AuditIssue{
+			Kind:    AuditStaleConfig,
+			Subject: change.Key,
+			Actual:  change.OldValue,
+		})
+	}
+	for _, include := range plan.RemoveIncludes {
+		result.Issues = append(result.Issues, AuditIssue{
+			Kind:    AuditInclude,
+			Subject: "include.path",
+			Actual:  include,
+		})
+	}
+	if plan.AddInclude != "" {
+		result.Issues = append(result.Issues, AuditIssue{
+			Kind:     AuditInclude,
+			Subject:  "include.path",
+			Expected: plan.AddInclude,
+		})
+	}
+	if plan.InstallGuard != "" {
+		result.Issues = append(result.Issues, AuditIssue{
+			Kind:     AuditGuard,
+			Subject:  "identity guard",
+			Expected: plan.InstallGuard,
+		})
+	}
+
+	return result, nil
+}
+
+// SkipProfile replaces the findings with an AuditProfileError issue when the
+// detected profile cannot be used, so nothing is fixed from it
+func (r *AuditResult) SkipProfile(err error) {
+	r.Issues = []AuditIssue{{Kind: AuditProfileError, Subject: "profile", Actual: err.Error()}}
+	r.Plan = nil
+}
+
+// Fixable reports whether gclone apply can resolve the issues found
+func (r *AuditResult) Fixable() bool {
+	return r.Plan != nil && !r.Plan.IsEmpty()
+}
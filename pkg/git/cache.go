@@ -0,0 +1,310 @@
+package git
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/fileutil"
+	"github.com/user-cube/gclone/pkg/ui"
+)
+
+// CacheEntry describes a bare mirror kept in the local object cache
+type CacheEntry struct {
+	Path              string
+	Size              int64
+	LastUsed          time.Time
+	FullCloneDuration time.Duration
+}
+
+// CacheUpdate reports what happened when a mirror was refreshed before a clone
+type CacheUpdate struct {
+	Entry    *CacheEntry
+	Created  bool
+	Duration time.Duration
+}
+
+// CacheDir returns the directory holding the bare mirror cache
+func CacheDir() string {
+	return filepath.Join(config.DefaultConfigDir(), "cache")
+}
+
+// CachePath returns the mirror location for a repository URL (<cache>/<host>/<path>.git)
+func CachePath(url string) (string, error) {
+	host, path, ok := ParseSSHURL(url)
+	if !ok {
+		return "", &UnsupportedURLError{URL: url}
+	}
+
+	path = strings.TrimSuffix(path, ".git") + ".git"
+	mirror := filepath.Join(CacheDir(), host, filepath.FromSlash(path))
+
+	// Refuse paths that would escape the cache directory
+	if rel, err := filepath.Rel(CacheDir(), mirror); err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("invalid repository path in URL: %s", url)
+	}
+
+	return mirror, nil
+}
+
+// lockMirror takes the lock of a mirror, so concurrent clones of the same
+// repository (common in CI) do not fetch into it at once and prune waits for
+// them. Lock files are kept apart from the mirrors and never removed, as
+// removing a lock file others wait on would let two holders in.
+func lockMirror(mirror string) (*fileutil.Lock, error) {
+	rel, err := filepath.Rel(CacheDir(), mirror)
+	if err != nil {
+		rel = mirror
+	}
+	sum := sha256.Sum256([]byte(filepath.ToSlash(rel)))
+	lock, err := fileutil.LockFile(filepath.Join(CacheDir(), ".locks", fmt.Sprintf("%x", sum[:8])))
+	if err != nil {
+		return nil, fmt.Errorf("error locking cache mirror %s: %w", mirror, err)
+	}
+	return lock, nil
+}
+
+// UpdateCache creates or refreshes the bare mirror for a repository.
+// The mirror is keyed by the original URL but fetched through the profile's SSH host.
+// Refreshing never prunes refs or collects garbage, since clones made without
+// --dissociate still read objects from the mirror through their alternates.
+func UpdateCache(url string, profile *config.Profile) (*CacheUpdate, error) {
+	mirror, err := CachePath(url)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteURL, err := TransformGitURL(url, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := lockMirror(mirror)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = lock.Unlock()
+	}()
+
+	update := &CacheUpdate{}
+	start := time.Now()
+
+	if _, err := os.Stat(filepath.Join(mirror, "HEAD")); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(mirror), 0755); err != nil {
+			return nil, fmt.Errorf("error creating cache directory: %w", err)
+		}
+
+		ui.Info("Populating cache %s\n", mirror)
+		if err := runGit("", sshEnv(profile), "clone", "--mirror", remoteURL, mirror); err != nil {
+			_ = os.RemoveAll(mirror)
+			return nil, fmt.Errorf("failed to create cache mirror: %w", err)
+		}
+		update.Created = true
+		update.Duration = time.Since(start)
+
+		// A fresh mirror costs about as much as an uncached clone, remember it
+		if err := setCacheValue(mirror, "fullCloneDuration", strconv.FormatInt(int64(update.Duration), 10)); err != nil {
+			return nil, err
+		}
+	} else {
+		ui.Info("Refreshing cache %s\n", mirror)
+		// The same repository may be fetched through different SSH hosts
+		if err := runGit(mirror, nil, "remote", "set-url", "origin", remoteURL); err != nil {
+			return nil, fmt.Errorf("failed to update cache remote: %w", err)
+		}
+		if err := runGit(mirror, sshEnv(profile), "-c", "gc.auto=0", "-c", "maintenance.auto=false", "fetch", "--quiet", "origin"); err != nil {
+			return nil, fmt.Errorf("failed to refresh cache mirror: %w", err)
+		}
+		update.Duration = time.Since(start)
+	}
+
+	if err := setCacheValue(mirror, "lastUsed", strconv.FormatInt(time.Now().Unix(), 10)); err != nil {
+		return nil, err
+	}
+
+	update.Entry, err = readCacheEntry(mirror)
+	if err != nil {
+		return nil, err
+	}
+
+	return update, nil
+}
+
+// TimeSaved estimates how much faster a cached clone was than an uncached one
+func (u *CacheUpdate) TimeSaved(cloneDuration time.Duration) time.Duration {
+	if u == nil || u.Created || u.Entry == nil || u.Entry.FullCloneDuration == 0 {
+		return 0
+	}
+
+	saved := u.Entry.FullCloneDuration - u.Duration - cloneDuration
+	if saved < 0 {
+		return 0
+	}
+	return saved
+}
+
+// ListCache returns every mirror in the cache, least recently used first
+func ListCache() ([]CacheEntry, error) {
+	root := CacheDir()
+	var entries []CacheEntry
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() || !strings.HasSuffix(path, ".git") {
+			return nil
+		}
+
+		entry, err := readCacheEntry(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, *entry)
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache directory: %w", err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+
+	return entries, nil
+}
+
+// PruneCache removes mirrors unused for longer than olderThan, then the least
+// recently used ones until the cache fits in maxSize. Zero disables a limit.
+// Each mirror is removed under its lock, after any clone refreshing it.
+func PruneCache(maxSize int64, olderThan time.Duration, dryRun bool) ([]CacheEntry, error) {
+	entries, err := ListCache()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+
+	var removed []CacheEntry
+	for _, entry := range entries {
+		expired := olderThan > 0 && time.Since(entry.LastUsed) > olderThan
+		oversized := maxSize > 0 && total > maxSize
+		if !expired && !oversized {
+			continue
+		}
+
+		if !dryRun {
+			if err := removeMirror(entry.Path); err != nil {
+				return removed, err
+			}
+		}
+		total -= entry.Size
+		removed = append(removed, entry)
+	}
+
+	return removed, nil
+}
+
+// removeMirror deletes a mirror under its lock
+func removeMirror(mirror string) error {
+	lock, err := lockMirror(mirror)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = lock.Unlock()
+	}()
+
+	if err := os.RemoveAll(mirror); err != nil {
+		return fmt.Errorf("error removing %s: %w", mirror, err)
+	}
+	removeEmptyParents(filepath.Dir(mirror), CacheDir())
+	return nil
+}
+
+// readCacheEntry collects the size and bookkeeping values of a mirror
+func readCacheEntry(mirror string) (*CacheEntry, error) {
+	entry := &CacheEntry{Path: mirror}
+
+	err := filepath.WalkDir(mirror, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			entry.Size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error measuring cache mirror %s: %w", mirror, err)
+	}
+
+	if value, err := strconv.ParseInt(getCacheValue(mirror, "lastUsed"), 10, 64); err == nil {
+		entry.LastUsed = time.Unix(value, 0)
+	}
+	if value, err := strconv.ParseInt(getCacheValue(mirror, "fullCloneDuration"), 10, 64); err == nil {
+		entry.FullCloneDuration = time.Duration(value)
+	}
+
+	return entry, nil
+}
+
+// getCacheValue reads gclone bookkeeping stored in the mirror's git config
+func getCacheValue(mirror, key string) string {
+	out, err := exec.Command("git", "config", "--file", filepath.Join(mirror, "config"), "gclone."+key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// setCacheValue stores gclone bookkeeping in the mirror's git config
+func setCacheValue(mirror, key, value string) error {
+	if err := exec.Command("git", "config", "--file", filepath.Join(mirror, "config"), "gclone."+key, value).Run(); err != nil {
+		return fmt.Errorf("failed to update cache metadata: %w", err)
+	}
+	return nil
+}
+
+// runGit runs a git command in dir, showing its output to the user on stderr.
+// A nil env inherits gclone's environment.
+func runGit(dir string, env []string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return newGitError(args, nil, err)
+	}
+	return nil
+}
+
+// removeEmptyParents removes empty directories from dir up to (not including) root
+func removeEmptyParents(dir, root string) {
+	for dir != root && strings.HasPrefix(dir, root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,109 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/sshconfig"
+)
+
+// EnvVar is an environment variable set for a profile
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// ProfileEnv returns the environment that makes git act as a profile in any
+// repository: the author and committer identity from user.name and user.email,
+// GIT_SSH_COMMAND for profiles with an identity file, and every other git
+// config through GIT_CONFIG_COUNT/KEY_n/VALUE_n. Profiles with an SSH host alias
+// get a url.<alias>.insteadOf config when the alias's real host is known from
+// hosts; otherwise a warning is returned alongside the variables.
+func ProfileEnv(profile *config.Profile, hosts *sshconfig.Config) ([]EnvVar, []string) {
+	var env []EnvVar
+	var warnings []string
+
+	if name, ok := profile.GitConfigs["user.name"]; ok {
+		env = append(env, EnvVar{"GIT_AUTHOR_NAME", name}, EnvVar{"GIT_COMMITTER_NAME", name})
+	}
+	if email, ok := profile.GitConfigs["user.email"]; ok {
+		env = append(env, EnvVar{"GIT_AUTHOR_EMAIL", email}, EnvVar{"GIT_COMMITTER_EMAIL", email})
+	}
+
+	configs := make(map[string]string)
+	for key, value := range profile.GitConfigs {
+		if key != "user.name" && key != "user.email" {
+			configs[key] = value
+		}
+	}
+
+	if sshCommand := SSHCommand(profile); sshCommand != "" {
+		env = append(env, EnvVar{"GIT_SSH_COMMAND", sshCommand})
+	} else if profile.SSHHost != "" {
+		// Send URLs of the real host through the profile's alias
+		if realHost, found := hosts.Hostname(profile.SSHHost); found && realHost != profile.SSHHost {
+			configs[fmt.Sprintf("url.git@%s:.insteadOf", profile.SSHHost)] = fmt.Sprintf("git@%s:", realHost)
+		} else {
+			warnings = append(warnings, fmt.Sprintf("SSH host %s has no Hostname in the SSH config, remotes are not rewritten to use it", profile.SSHHost))
+		}
+	}
+
+	keys := make([]string, 0, len(configs))
+	for key := range configs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if len(keys) > 0 {
+		env = append(env, EnvVar{"GIT_CONFIG_COUNT", strconv.Itoa(len(keys))})
+		for i, key := range keys {
+			env = append(env,
+				EnvVar{fmt.Sprintf("GIT_CONFIG_KEY_%d", i), key},
+				EnvVar{fmt.Sprintf("GIT_CONFIG_VALUE_%d", i), configs[key]})
+		}
+	}
+
+	return env, warnings
+}
+
+// ApplyEnv returns environ with the profile variables set. Git configs already
+// passed through GIT_CONFIG_COUNT in environ are kept, the profile's are added after them.
+func ApplyEnv(environ []string, vars []EnvVar) []string {
+	existing := 0
+	for _, entry := range environ {
+		if value, found := strings.CutPrefix(entry, "GIT_CONFIG_COUNT="); found {
+			existing, _ = strconv.Atoi(value)
+		}
+	}
+
+	renumbered := make([]EnvVar, 0, len(vars))
+	names := make(map[string]bool, len(vars))
+	for _, v := range vars {
+		switch {
+		case v.Name == "GIT_CONFIG_COUNT":
+			count, _ := strconv.Atoi(v.Value)
+			v.Value = strconv.Itoa(existing + count)
+		case strings.HasPrefix(v.Name, "GIT_CONFIG_KEY_"), strings.HasPrefix(v.Name, "GIT_CONFIG_VALUE_"):
+			prefix := v.Name[:strings.LastIndexByte(v.Name, '_')+1]
+			index, _ := strconv.Atoi(strings.TrimPrefix(v.Name, prefix))
+			v.Name = prefix + strconv.Itoa(existing+index)
+		}
+		renumbered = append(renumbered, v)
+		names[v.Name] = true
+	}
+
+	result := make([]string, 0, len(environ)+len(renumbered))
+	for _, entry := range environ {
+		name, _, _ := strings.Cut(entry, "=")
+		if !names[name] {
+			result = append(result, entry)
+		}
+	}
+	for _, v := range renumbered {
+		result = append(result, v.Name+"="+v.Value)
+	}
+	return result
+}
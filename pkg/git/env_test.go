@@ -0,0 +1,100 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyEnv(t *testing.T) {
+	vars := []EnvVar{
+		{"GIT_AUTHOR_NAME", "Work"},
+		{"GIT_CONFIG_COUNT", "2"},
+		{"GIT_CONFIG_KEY_0", "commit.gpgsign"},
+		{"GIT_CONFIG_VALUE_0", "true"},
+		{"GIT_CONFIG_KEY_1", "core.autocrlf"},
+		{"GIT_CONFIG_VALUE_1", "input"},
+	}
+
+	tests := []struct {
+		name    string
+		environ []string
+		want    []string
+	}{
+		{
+			name:    "no configs passed",
+			environ: []string{"PATH=/bin"},
+			want: []string{
+				"PATH=/bin",
+				"GIT_AUTHOR_NAME=Work",
+				"GIT_CONFIG_COUNT=2",
+				"GIT_CONFIG_KEY_0=commit.gpgsign",
+				"GIT_CONFIG_VALUE_0=true",
+				"GIT_CONFIG_KEY_1=core.autocrlf",
+				"GIT_CONFIG_VALUE_1=input",
+			},
+		},
+		{
+			name: "configs already passed",
+			environ: []string{
+				"GIT_CONFIG_COUNT=1",
+				"GIT_CONFIG_KEY_0=pull.rebase",
+				"GIT_CONFIG_VALUE_0=true",
+				"PATH=/bin",
+			},
+			want: []string{
+				"GIT_CONFIG_KEY_0=pull.rebase",
+				"GIT_CONFIG_VALUE_0=true",
+				"PATH=/bin",
+				"GIT_AUTHOR_NAME=Work",
+				"GIT_CONFIG_COUNT=3",
+				"GIT_CONFIG_KEY_1=commit.gpgsign",
+				"GIT_CONFIG_VALUE_1=true",
+				"GIT_CONFIG_KEY_2=core.autocrlf",
+				"GIT_CONFIG_VALUE_2=input",
+			},
+		},
+		{
+			name: "profile variables replaced",
+			environ: []string{
+				"GIT_AUTHOR_NAME=Personal",
+				"GIT_CONFIG_COUNT=2",
+				"GIT_CONFIG_KEY_0=pull.rebase",
+				"GIT_CONFIG_VALUE_0=true",
+				"GIT_CONFIG_KEY_1=fetch.prune",
+				"GIT_CONFIG_VALUE_1=true",
+			},
+			want: []string{
+				"GIT_CONFIG_KEY_0=pull.rebase",
+				"GIT_CONFIG_VALUE_0=true",
+				"GIT_CONFIG_KEY_1=fetch.prune",
+				"GIT_CONFIG_VALUE_1=true",
+				"GIT_AUTHOR_NAME=Work",
+				"GIT_CONFIG_COUNT=4",
+				"GIT_CONFIG_KEY_2=commit.gpgsign",
+				"GIT_CONFIG_VALUE_2=true",
+				"GIT_CONFIG_KEY_3=core.autocrlf",
+				"GIT_CONFIG_VALUE_3=input",
+			},
+		},
+		{
+			name:    "invalid count",
+			environ: []string{"GIT_CONFIG_COUNT=many"},
+			want: []string{
+				"GIT_AUTHOR_NAME=Work",
+				"GIT_CONFIG_COUNT=2",
+				"GIT_CONFIG_KEY_0=commit.gpgsign",
+				"GIT_CONFIG_VALUE_0=true",
+				"GIT_CONFIG_KEY_1=core.autocrlf",
+				"GIT_CONFIG_VALUE_1=input",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ApplyEnv(tt.environ, vars); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ApplyEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
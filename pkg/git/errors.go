@@ -0,0 +1,74 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// UnsupportedURLError is returned for repository URLs gclone cannot handle
+type UnsupportedURLError struct {
+	URL string
+}
+
+func (e *UnsupportedURLError) Error() string {
+	return fmt.Sprintf("unsupported git URL format: %s (only SSH URLs are supported)", e.URL)
+}
+
+// GitError is returned when a git command fails
+type GitError struct {
+	// Args are the arguments git was run with
+	Args []string
+	// ExitCode is git's exit status, or -1 if git could not be run at all
+	ExitCode int
+	// Message is the most relevant line of git's output, if any
+	Message string
+	// LogFile is where git's full output was saved, if it was
+	LogFile string
+	Err     error
+}
+
+func (e *GitError) Error() string {
+	command := "git"
+	if len(e.Args) > 0 {
+		command += " " + e.Args[0]
+	}
+
+	msg := fmt.Sprintf("%s failed", command)
+	if e.ExitCode >= 0 {
+		msg += fmt.Sprintf(" with exit status %d", e.ExitCode)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	} else if e.ExitCode < 0 && e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.LogFile != "" {
+		msg += fmt.Sprintf(" (git output saved to %s)", e.LogFile)
+	}
+	return msg
+}
+
+func (e *GitError) Unwrap() error {
+	return e.Err
+}
+
+// newGitError wraps the error of a git command run with args
+func newGitError(args []string, output []byte, err error) *GitError {
+	gitErr := &GitError{
+		Args:     args,
+		ExitCode: -1,
+		Message:  lastErrorLine(output),
+		Err:      err,
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		gitErr.ExitCode = exitErr.ExitCode()
+		if gitErr.Message == "" {
+			gitErr.Message = lastErrorLine(exitErr.Stderr)
+		}
+	}
+
+	return gitErr
+}
@@ -4,44 +4,114 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/registry"
 	"github.com/user-cube/gclone/pkg/ui"
 )
 
+// sshURLRegex matches the SSH URL format (git@github.com:user/repo.git)
+var sshURLRegex = regexp.MustCompile(`^git@([^:]+):(.+)$`)
+
+// ParseSSHURL splits an SSH git URL into its host and repository path
+func ParseSSHURL(url string) (host, path string, ok bool) {
+	matches := sshURLRegex.FindStringSubmatch(url)
+	if len(matches) != 3 {
+		return "", "", false
+	}
+	return matches[1], matches[2], true
+}
+
 // TransformGitURL transforms a git URL to use the specified SSH host
 func TransformGitURL(url string, profile *config.Profile) (string, error) {
-	if profile == nil || profile.SSHHost == "" {
+	// Profiles with an identity file reach the original host through core.sshCommand
+	if profile == nil || profile.SSHHost == "" || profile.IdentityFile != "" {
 		return url, nil
 	}
 
 	// Handle SSH URL format (git@github.com:user/repo.git)
-	sshRegex := regexp.MustCompile(`^git@([^:]+):(.+)$`)
-	if matches := sshRegex.FindStringSubmatch(url); len(matches) == 3 {
-		// We don't need to use originalHost, just get the path part
-		path := matches[2]
-
+	if _, path, ok := ParseSSHURL(url); ok {
 		// Replace the host with the profile's SSH host
 		return fmt.Sprintf("git@%s:%s", profile.SSHHost, path), nil
 	}
 
-	return url, fmt.Errorf("unsupported git URL format: %s (only SSH URLs are supported)", url)
+	return url, &UnsupportedURLError{URL: url}
 }
 
-// CloneRepository clones a repository using the specified profile
-func CloneRepository(url, destination string, profile *config.Profile, extraArgs []string) error {
+// CloneOptions holds optional settings for CloneRepository
+type CloneOptions struct {
+	// ProfileName is the configuration key of the profile, reported in the result
+	ProfileName string
+	// ExtraArgs are passed to git clone as-is
+	ExtraArgs []string
+	// Verbose shows git's raw output instead of the progress bar
+	Verbose bool
+	// ConfigFile is the gclone config file the identity guard reads, empty for the default
+	ConfigFile string
+	// Version is the gclone version recorded in the registry
+	Version string
+	// Secret reports the git config keys whose values came from secret files
+	// (see config.Config.IsSecret); they are masked in the result
+	Secret func(key string) bool
+}
+
+// CloneResult describes what CloneRepository did, for reporting to the user or to tools
+type CloneResult struct {
+	Profile        string            `json:"profile"`
+	OriginalURL    string            `json:"original_url"`
+	TransformedURL string            `json:"transformed_url"`
+	Destination    string            `json:"destination"`
+	ConfigsApplied map[string]string `json:"configs_applied"`
+	HooksRun       []string          `json:"hooks_run"`
+	DurationMS     int64             `json:"duration_ms"`
+	Error          *string           `json:"error"`
+}
+
+// NewCloneResult creates an empty result for a clone of url
+func NewCloneResult(url, profileName string) *CloneResult {
+	return &CloneResult{
+		Profile:        profileName,
+		OriginalURL:    url,
+		ConfigsApplied: map[string]string{},
+		HooksRun:       []string{},
+	}
+}
+
+// SetError records the error that ended the clone, if any
+func (r *CloneResult) SetError(err error) {
+	if err == nil {
+		r.Error = nil
+		return
+	}
+	msg := err.Error()
+	r.Error = &msg
+}
+
+// CloneRepository clones a repository using the specified profile.
+// The returned result is never nil and describes as much as was done, even on error.
+func CloneRepository(url, destination string, profile *config.Profile, opts CloneOptions) (*CloneResult, error) {
+	result := NewCloneResult(url, opts.ProfileName)
+	start := time.Now()
+	defer func() {
+		result.DurationMS = time.Since(start).Milliseconds()
+	}()
+
 	if profile != nil {
 		var err error
 		url, err = TransformGitURL(url, profile)
 		if err != nil {
-			return err
+			return result, err
 		}
 	}
+	result.TransformedURL = url
 
 	// Prepare the git clone command
-	args := []string{"clone", url}
+	args := []string{"clone", "--progress", url}
 
 	// Add destination if provided
 	if destination != "" {
@@ -55,41 +125,105 @@ func CloneRepository(url, destination string, profile *config.Profile, extraArgs
 		}
 	}
 
+	if absDestination, err := filepath.Abs(destination); err == nil {
+		result.Destination = absDestination
+	} else {
+		result.Destination = destination
+	}
+
 	// Add any extra arguments
-	if len(extraArgs) > 0 {
-		args = append(args, extraArgs...)
+	if len(opts.ExtraArgs) > 0 {
+		args = append(args, opts.ExtraArgs...)
 	}
 
 	// Execute the git clone command
-	ui.Info("Running git %s", strings.Join(args, " "))
-	cmd := exec.Command("git", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	ui.Info("Running git %s\n", strings.Join(args, " "))
+	output, err := runGitWithProgress(opts.Verbose, sshEnv(profile), args...)
+	if err != nil {
+		// Keep git's own output around, it is the only useful trace of what went wrong
+		gitErr := newGitError(args, output, err)
+		if logFile, logErr := saveGitLog("clone", output); logErr == nil {
+			gitErr.LogFile = logFile
+		}
+		return result, gitErr
+	}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("git clone failed: %w", err)
+	var profileConfigs map[string]string
+	if profile != nil {
+		profileConfigs = ProfileGitConfigs(profile)
 	}
 
-	// Apply Git configurations if a profile is specified
-	if profile != nil && len(profile.GitConfigs) > 0 {
-		repoPath := destination
-		if repoPath == "" {
-			// Extract repository name from URL
-			parts := strings.Split(url, "/")
-			repoName := strings.TrimSuffix(parts[len(parts)-1], ".git")
-			repoPath = repoName
+	// Link the profile's generated config file instead of copying values in include mode
+	if profile != nil && profile.UsesInclude() && opts.ProfileName != "" {
+		if err := WriteProfileConfigFile(opts.ProfileName, profile); err != nil {
+			return result, err
 		}
-
+		includeFile := ProfileConfigFile(opts.ProfileName)
+		ui.Info("Adding include.path %s\n", includeFile)
+		if err := AddInclude(destination, includeFile); err != nil {
+			return result, fmt.Errorf("failed to add include.path: %w", err)
+		}
+		result.addConfigs(profileConfigs, opts.Secret)
+	} else if profile != nil && len(profileConfigs) > 0 {
 		// Apply Git configurations
-		if err := ApplyGitConfigs(repoPath, profile.GitConfigs); err != nil {
-			return fmt.Errorf("failed to apply git configs: %w", err)
+		if err := ApplyGitConfigs(destination, profileConfigs); err != nil {
+			return result, fmt.Errorf("failed to apply git configs: %w", err)
 		}
+		result.addConfigs(profileConfigs, opts.Secret)
 	}
 
-	return nil
+	if profile != nil && profile.IdentityGuard != "" {
+		ui.Info("Installing identity guard (%s)\n", profile.IdentityGuard)
+		if err := InstallGuard(destination, profile.IdentityGuard, opts.ConfigFile); err != nil {
+			return result, fmt.Errorf("failed to install identity guard: %w", err)
+		}
+		result.HooksRun = append(result.HooksRun, "identity-guard:"+profile.IdentityGuard)
+	}
+
+	// Remember which keys came from the profile so gclone sync can update or remove them later
+	if profile != nil && opts.ProfileName != "" {
+		keys := make([]string, 0, len(profileConfigs))
+		if !profile.UsesInclude() {
+			for key := range profileConfigs {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		if err := WriteProfileRecord(destination, opts.ProfileName, keys); err != nil {
+			ui.Warning("Could not record profile in the repository: %v\n", err)
+		}
+	}
+
+	// Remember the repository; the clone itself succeeded, so failing here is only a warning
+	err = registry.Update(registry.DefaultRegistryFile(), func(r *registry.Registry) error {
+		r.Add(registry.Entry{
+			Path:          result.Destination,
+			URL:           result.OriginalURL,
+			Profile:       opts.ProfileName,
+			ClonedAt:      time.Now(),
+			GcloneVersion: opts.Version,
+		})
+		return nil
+	})
+	if err != nil {
+		ui.Warning("Could not record repository in the registry: %v\n", err)
+	}
+
+	return result, nil
+}
+
+// addConfigs records the git configs applied by a clone, masking secret values
+func (r *CloneResult) addConfigs(configs map[string]string, secret func(key string) bool) {
+	for key, value := range configs {
+		if secret != nil && secret(key) {
+			value = config.MaskedValue
+		}
+		r.ConfigsApplied[key] = value
+	}
 }
 
-// ApplyGitConfigs applies Git configurations to a repository
+// ApplyGitConfigs applies Git configurations to a repository. Only the keys
+// are shown, values may have been read from secret files.
 func ApplyGitConfigs(repoPath string, configs map[string]string) error {
 	// Ensure the path exists
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
@@ -98,12 +232,13 @@ func ApplyGitConfigs(repoPath string, configs map[string]string) error {
 
 	// Apply each configuration
 	for key, value := range configs {
-		ui.Info("Setting git config %s=%s", key, value)
-		cmd := exec.Command("git", "config", "--local", key, value)
+		ui.Info("Setting git config %s\n", key)
+		args := []string{"config", "--local", key, value}
+		cmd := exec.Command("git", args...)
 		cmd.Dir = repoPath
 
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to set git config %s=%s: %w", key, value, err)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to set git config %s: %w", key, newGitError(args, out, err))
 		}
 	}
 
@@ -0,0 +1,278 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/fileutil"
+)
+
+const (
+	// guardMarker identifies hook scripts written by gclone
+	guardMarker = "# gclone identity guard"
+	// chainedSuffix is appended to a pre-commit hook gclone moved aside to run after its own
+	chainedSuffix = ".gclone-chained"
+	// chainedHooksPathKey remembers the hooks directory in use before core.hooksPath pointed at gclone
+	chainedHooksPathKey = "gclone.chainedhookspath"
+	// guardConfigKey holds the gclone config file the guard reads, when not the default
+	guardConfigKey = "gclone.configfile"
+)
+
+// clientHooks are the hooks gclone's hooks directory forwards to the repository's own hooks
+var clientHooks = []string{
+	"applypatch-msg", "pre-applypatch", "post-applypatch",
+	"pre-commit", "pre-merge-commit", "prepare-commit-msg", "commit-msg", "post-commit",
+	"pre-rebase", "post-checkout", "post-merge", "pre-push", "post-rewrite",
+	"pre-auto-gc", "reference-transaction", "sendemail-validate", "post-index-change",
+}
+
+// IdentityError is returned when a commit identity does not match the repository's profile
+type IdentityError struct {
+	Profile string
+	Role    string
+	Email   string
+	// Expected is the profile's user.email, empty when only domains are restricted
+	Expected string
+	Domains  []string
+}
+
+func (e *IdentityError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "commit blocked: %s email %q does not match profile '%s'", e.Role, e.Email, e.Profile)
+	if e.Expected != "" {
+		fmt.Fprintf(&b, "\n  expected: %s", e.Expected)
+		fmt.Fprintf(&b, "\n  fix it with: git config user.email %s", e.Expected)
+	} else {
+		fmt.Fprintf(&b, "\n  allowed domains: %s", strings.Join(e.Domains, ", "))
+	}
+	b.WriteString("\n  or commit once without the check: git commit --no-verify")
+	return b.String()
+}
+
+// GuardHooksDir returns the hooks directory used with the hooks-path identity guard
+func GuardHooksDir() string {
+	return filepath.Join(config.DefaultConfigDir(), "hooks")
+}
+
+// CheckIdentity compares the author and committer of the next commit with a profile:
+// the email must equal the profile's user.email and belong to one of its allowed domains
+func CheckIdentity(repoPath, profileName string, profile *config.Profile) error {
+	expected := profile.GitConfigs["user.email"]
+
+	for _, role := range []string{"author", "committer"} {
+		ident, err := gitOutput(repoPath, "var", "GIT_"+strings.ToUpper(role)+"_IDENT")
+		if err != nil {
+			return fmt.Errorf("error reading %s identity: %w", role, err)
+		}
+		email := identEmail(ident)
+
+		if expected != "" && !strings.EqualFold(email, expected) {
+			return &IdentityError{Profile: profileName, Role: role, Email: email, Expected: expected}
+		}
+		if len(profile.AllowedEmailDomains) > 0 && !emailInDomains(email, profile.AllowedEmailDomains) {
+			return &IdentityError{Profile: profileName, Role: role, Email: email, Domains: profile.AllowedEmailDomains}
+		}
+	}
+
+	return nil
+}
+
+// GuardInstalled reports whether the identity guard is active in a repository
+func GuardInstalled(repoPath string) (bool, error) {
+	hooksDir, err := gitOutput(repoPath, "rev-parse", "--path-format=absolute", "--git-path", "hooks")
+	if err != nil {
+		return false, err
+	}
+	if hooksDir == GuardHooksDir() {
+		return true, nil
+	}
+	return isGuardHook(filepath.Join(hooksDir, "pre-commit")), nil
+}
+
+// InstallGuard installs the identity guard in a repository in the given mode.
+// Existing hooks keep running after the check. configFile is the gclone config
+// the check reads, empty for the default.
+func InstallGuard(repoPath, mode, configFile string) error {
+	gclone, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("error locating the gclone binary: %w", err)
+	}
+
+	if configFile != "" {
+		if configFile, err = filepath.Abs(configFile); err != nil {
+			return err
+		}
+		if _, err := gitOutput(repoPath, "config", "--local", guardConfigKey, configFile); err != nil {
+			return fmt.Errorf("failed to record config file: %w", err)
+		}
+	} else if err := UnsetLocalConfig(repoPath, guardConfigKey); err != nil {
+		return err
+	}
+
+	switch mode {
+	case config.GuardPreCommit:
+		return installPreCommitGuard(repoPath, gclone)
+	case config.GuardHooksPath:
+		return installHooksPathGuard(repoPath, gclone)
+	default:
+		return fmt.Errorf("unknown identity guard mode %q (expected %s or %s)", mode, config.GuardPreCommit, config.GuardHooksPath)
+	}
+}
+
+// UninstallGuard removes the identity guard from a repository and restores the hooks it chained
+func UninstallGuard(repoPath string) error {
+	hooksPath, isSet, err := GetLocalConfig(repoPath, "core.hooksPath")
+	if err != nil {
+		return err
+	}
+	if isSet && hooksPath == GuardHooksDir() {
+		chained, _, err := GetLocalConfig(repoPath, chainedHooksPathKey)
+		if err != nil {
+			return err
+		}
+		if err := UnsetLocalConfig(repoPath, "core.hooksPath"); err != nil {
+			return err
+		}
+		// Restore a local hooks path; a global one applies again by itself
+		if effective, _ := gitOutput(repoPath, "config", "core.hooksPath"); chained != "" && chained != effective {
+			if _, err := gitOutput(repoPath, "config", "--local", "core.hooksPath", chained); err != nil {
+				return fmt.Errorf("failed to restore core.hooksPath: %w", err)
+			}
+		}
+		if err := UnsetLocalConfig(repoPath, chainedHooksPathKey); err != nil {
+			return err
+		}
+	}
+
+	hooksDir, err := gitOutput(repoPath, "rev-parse", "--path-format=absolute", "--git-path", "hooks")
+	if err != nil {
+		return err
+	}
+	hook := filepath.Join(hooksDir, "pre-commit")
+	if isGuardHook(hook) {
+		if err := os.Remove(hook); err != nil {
+			return fmt.Errorf("error removing %s: %w", hook, err)
+		}
+		if _, err := os.Stat(hook + chainedSuffix); err == nil {
+			if err := os.Rename(hook+chainedSuffix, hook); err != nil {
+				return fmt.Errorf("error restoring %s: %w", hook, err)
+			}
+		}
+	}
+
+	return UnsetLocalConfig(repoPath, guardConfigKey)
+}
+
+// installPreCommitGuard writes a pre-commit hook into the repository's hooks
+// directory, moving an existing hook aside to be chained
+func installPreCommitGuard(repoPath, gclone string) error {
+	hooksDir, err := gitOutput(repoPath, "rev-parse", "--path-format=absolute", "--git-path", "hooks")
+	if err != nil {
+		return err
+	}
+	if hooksDir == GuardHooksDir() {
+		return errors.New("the hooks-path identity guard is installed, uninstall it first")
+	}
+
+	hook := filepath.Join(hooksDir, "pre-commit")
+	if _, err := os.Stat(hook); err == nil && !isGuardHook(hook) {
+		if _, err := os.Stat(hook + chainedSuffix); err == nil {
+			return fmt.Errorf("cannot chain %s: %s already exists", hook, hook+chainedSuffix)
+		}
+		if err := os.Rename(hook, hook+chainedSuffix); err != nil {
+			return fmt.Errorf("error moving existing hook aside: %w", err)
+		}
+	}
+
+	script := guardScript(gclone, true) + `chained="$(dirname "$0")/pre-commit` + chainedSuffix + `"
+if [ -x "$chained" ]; then
+	exec "$chained" "$@"
+fi
+`
+	return fileutil.WriteFileAtomic(hook, []byte(script), 0755)
+}
+
+// installHooksPathGuard points core.hooksPath at gclone's hooks directory, whose
+// hooks run the identity check and then the hooks the repository used before
+func installHooksPathGuard(repoPath, gclone string) error {
+	dir := GuardHooksDir()
+	for _, name := range clientHooks {
+		script := guardScript(gclone, name == "pre-commit") + `hooks="$(git config ` + chainedHooksPathKey + ` || echo "$(git rev-parse --git-common-dir)/hooks")"
+if [ -x "$hooks/` + name + `" ]; then
+	exec "$hooks/` + name + `" "$@"
+fi
+`
+		if err := fileutil.WriteFileAtomic(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			return err
+		}
+	}
+
+	current, _ := gitOutput(repoPath, "config", "core.hooksPath")
+	if current == dir {
+		return nil
+	}
+	if current != "" {
+		if _, err := gitOutput(repoPath, "config", "--local", chainedHooksPathKey, current); err != nil {
+			return fmt.Errorf("failed to record previous core.hooksPath: %w", err)
+		}
+	}
+	if _, err := gitOutput(repoPath, "config", "--local", "core.hooksPath", dir); err != nil {
+		return fmt.Errorf("failed to set core.hooksPath: %w", err)
+	}
+	return nil
+}
+
+// guardScript returns the start of a hook script, running the identity check when check is set
+func guardScript(gclone string, check bool) string {
+	script := "#!/bin/sh\n" + guardMarker + " (remove with 'gclone guard uninstall')\n"
+	if check {
+		quoted := ShellQuote(gclone)
+		script += `if [ -x ` + quoted + ` ]; then
+	` + quoted + ` guard check || exit 1
+else
+	echo "gclone identity guard: ` + gclone + ` not found, commit identity not checked" >&2
+fi
+`
+	}
+	return script
+}
+
+// isGuardHook reports whether a hook script was written by gclone
+func isGuardHook(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && strings.Contains(string(data), guardMarker)
+}
+
+// identEmail extracts the email from a git identity ("Name <email> time zone")
+func identEmail(ident string) string {
+	start := strings.IndexByte(ident, '<')
+	end := strings.LastIndexByte(ident, '>')
+	if start < 0 || end < start {
+		return ""
+	}
+	return ident[start+1 : end]
+}
+
+// emailInDomains reports whether an email address belongs to one of the domains
+func emailInDomains(email string, domains []string) bool {
+	_, domain, found := strings.Cut(email, "@")
+	if !found {
+		return false
+	}
+	for _, allowed := range domains {
+		if strings.EqualFold(domain, strings.TrimPrefix(allowed, "@")) {
+			return true
+		}
+	}
+	return false
+}
+
+// GuardConfigFile returns the gclone config file recorded for a repository's identity guard
+func GuardConfigFile(repoPath string) string {
+	configFile, _, _ := GetLocalConfig(repoPath, guardConfigKey)
+	return configFile
+}
@@ -0,0 +1,124 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/user-cube/gclone/pkg/config"
+)
+
+// ProfileConfigDir returns the directory holding the git config files generated from profiles
+func ProfileConfigDir() string {
+	return filepath.Join(config.DefaultConfigDir(), "gitconfig")
+}
+
+// ProfileConfigFile returns the generated git config file of a profile
+func ProfileConfigFile(profileName string) string {
+	return filepath.Join(ProfileConfigDir(), profileName+".gitconfig")
+}
+
+// WriteProfileConfigFile generates the git config file of a profile, replacing any previous version
+func WriteProfileConfigFile(profileName string, profile *config.Profile) error {
+	path := ProfileConfigFile(profileName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating %s: %w", filepath.Dir(path), err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+profileName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	header := fmt.Sprintf("# Generated by gclone from profile '%s', edit the profile instead\n", profileName)
+	_, err = tmp.WriteString(header)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+
+	configs := ProfileGitConfigs(profile)
+	keys := make([]string, 0, len(configs))
+	for key := range configs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Let git do the quoting so any value round-trips
+	for _, key := range keys {
+		if _, err := gitOutput("", "config", "--file", tmp.Name(), key, configs[key]); err != nil {
+			return fmt.Errorf("error writing %s to %s: %w", key, path, err)
+		}
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteProfileConfigFiles regenerates the git config file of every profile and
+// removes the files of profiles that no longer exist or became abstract.
+// Profiles whose references could not be expanded (see config.Config.ProfileError)
+// keep their previous file.
+func WriteProfileConfigFiles(cfg *config.Config) error {
+	for _, name := range cfg.ProfileNames() {
+		if cfg.ProfileError(name) != nil {
+			continue
+		}
+		profile := cfg.Profiles[name]
+		if err := WriteProfileConfigFile(name, &profile); err != nil {
+			return err
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(ProfileConfigDir(), "*.gitconfig"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".gitconfig")
+		if profile, exists := cfg.Profiles[name]; !exists || profile.Abstract {
+			if err := os.Remove(file); err != nil {
+				return fmt.Errorf("error removing %s: %w", file, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ListIncludes returns the include.path entries of a repository's local git config
+func ListIncludes(repoPath string) ([]string, error) {
+	out, err := gitOutput(repoPath, "config", "--local", "--get-all", "include.path")
+	if err != nil {
+		var gitErr *GitError
+		if errors.As(err, &gitErr) && gitErr.ExitCode == 1 {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// AddInclude adds an include.path entry to a repository's local git config
+func AddInclude(repoPath, path string) error {
+	_, err := gitOutput(repoPath, "config", "--local", "--add", "include.path", path)
+	return err
+}
+
+// RemoveInclude removes an include.path entry from a repository's local git config
+func RemoveInclude(repoPath, path string) error {
+	_, err := gitOutput(repoPath, "config", "--local", "--fixed-value", "--unset-all", "include.path", path)
+	return err
+}
+
+// isProfileConfigFile reports whether an include.path entry points at a file generated by gclone
+func isProfileConfigFile(path string) bool {
+	return filepath.Dir(path) == ProfileConfigDir() && strings.HasSuffix(path, ".gitconfig")
+}
@@ -0,0 +1,129 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/fileutil"
+)
+
+// Markers around the section of the global git config owned by gclone
+const (
+	managedSectionBegin = "# BEGIN gclone managed section (changes are overwritten by 'gclone gitconfig install')"
+	managedSectionEnd   = "# END gclone managed section"
+)
+
+// GlobalConfigFile returns the path of the user's global git config
+func GlobalConfigFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".gitconfig"
+	}
+	return filepath.Join(home, ".gitconfig")
+}
+
+// RenderIncludeIfSection builds the managed section mapping each profile root to
+// the profile's generated config file. Profiles without roots are left out.
+func RenderIncludeIfSection(cfg *config.Config) string {
+	var b strings.Builder
+	b.WriteString(managedSectionBegin + "\n")
+	for _, name := range cfg.ProfileNames() {
+		for _, root := range cfg.Profiles[name].Roots {
+			// gitdir patterns only match the directory and everything below it with a trailing slash
+			if !strings.HasSuffix(root, "/") {
+				root += "/"
+			}
+			fmt.Fprintf(&b, "[includeIf %s]\n", quoteConfigValue("gitdir:"+root))
+			fmt.Fprintf(&b, "\tpath = %s\n", quoteConfigValue(ProfileConfigFile(name)))
+		}
+	}
+	b.WriteString(managedSectionEnd + "\n")
+	return b.String()
+}
+
+// InstallIncludeIf writes the managed section into a git config file, replacing
+// the previous one. The previous file is kept in config.BackupDir with the
+// config file backups (see config.BackupFile). It returns the backup path,
+// empty when nothing changed.
+func InstallIncludeIf(file, section string) (string, error) {
+	return updateManagedSection(file, section)
+}
+
+// UninstallIncludeIf removes the managed section from a git config file. It
+// returns the backup path, empty when there was no section to remove.
+func UninstallIncludeIf(file string) (string, error) {
+	return updateManagedSection(file, "")
+}
+
+// updateManagedSection replaces the managed section of a file, or removes it when section is empty
+func updateManagedSection(file, section string) (string, error) {
+	// Dotfile managers often symlink ~/.gitconfig, write through to the real file
+	if resolved, err := filepath.EvalSymlinks(file); err == nil {
+		file = resolved
+	}
+
+	perm := os.FileMode(0644)
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("error reading %s: %w", file, err)
+	}
+	if info, statErr := os.Stat(file); statErr == nil {
+		perm = info.Mode().Perm()
+	}
+
+	content, err := replaceManagedSection(string(data), section)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", file, err)
+	}
+	if content == string(data) {
+		return "", nil
+	}
+
+	// Backups rotate like those of config files instead of piling up next to
+	// the file. An empty backup still records that the file did not exist.
+	backup, err := config.BackupFile(file, data)
+	if err != nil {
+		return "", fmt.Errorf("error backing up %s: %w", file, err)
+	}
+	if err := fileutil.WriteFileAtomic(file, []byte(content), perm); err != nil {
+		return "", fmt.Errorf("error writing %s: %w", file, err)
+	}
+	return backup, nil
+}
+
+// replaceManagedSection swaps the managed section in content for section. A new
+// section goes at the end of the file, after the user's own settings. A begin
+// marker without its end marker is an error: where the section ends cannot be
+// told, and adding a second one would leave both in the file.
+func replaceManagedSection(content, section string) (string, error) {
+	begin := strings.Index(content, managedSectionBegin)
+	if begin >= 0 {
+		end := strings.Index(content[begin:], managedSectionEnd)
+		if end < 0 {
+			return "", fmt.Errorf("the gclone section has no %q line, add it after the section or remove the section by hand", managedSectionEnd)
+		}
+		end = begin + end + len(managedSectionEnd)
+		if end < len(content) && content[end] == '\n' {
+			end++
+		}
+		return content[:begin] + section + content[end:], nil
+	}
+
+	if section == "" {
+		return content, nil
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content + section, nil
+}
+
+// quoteConfigValue quotes a string for use as a git config value or subsection name
+func quoteConfigValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
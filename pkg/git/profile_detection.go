@@ -1,6 +1,7 @@
 package git
 
 import (
+	"sort"
 	"strings"
 
 	"github.com/user-cube/gclone/pkg/config"
@@ -8,12 +9,31 @@ import (
 
 // DetectProfileForURL determines which profile to use based on the repository URL
 func DetectProfileForURL(url string, profiles map[string]config.Profile) (string, bool) {
+	// Check profiles in a stable order so overlapping patterns always resolve the same way
+	names := make([]string, 0, len(profiles))
+	for name, profile := range profiles {
+		// Abstract profiles only carry settings for the profiles extending them
+		if !profile.Abstract {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	// A URL that already goes through a profile's SSH host belongs to that profile
+	if host, _, ok := ParseSSHURL(url); ok {
+		for _, name := range names {
+			if sshHost := profiles[name].SSHHost; sshHost != "" && sshHost == host {
+				return name, true
+			}
+		}
+	}
+
 	// Normalize the URL to handle SSH format
 	normalizedURL := NormalizeURL(url)
 
 	// Check each profile for matching URL patterns
-	for name, profile := range profiles {
-		for _, pattern := range profile.URLPatterns {
+	for _, name := range names {
+		for _, pattern := range profiles[name].URLPatterns {
 			if strings.Contains(normalizedURL, pattern) {
 				return name, true
 			}
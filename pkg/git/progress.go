@@ -0,0 +1,134 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/ui"
+)
+
+// ProgressUpdate is a single progress report parsed from git's --progress output
+type ProgressUpdate struct {
+	Phase   string
+	Percent int
+	Detail  string
+}
+
+// progressRegex matches lines such as
+// "remote: Counting objects:  45% (9/20)" or
+// "Receiving objects: 100% (20/20), 1.20 MiB | 2.00 MiB/s, done."
+var progressRegex = regexp.MustCompile(`^(?:remote: )?([A-Z][A-Za-z ]+?):\s+(\d+)% \((\d+/\d+)\)(?:, (.*?))?(?:, done\.)?\s*$`)
+
+// ParseProgressLine parses one line of git's progress output
+func ParseProgressLine(line string) (ProgressUpdate, bool) {
+	matches := progressRegex.FindStringSubmatch(strings.TrimSpace(line))
+	if matches == nil {
+		return ProgressUpdate{}, false
+	}
+
+	update := ProgressUpdate{Phase: matches[1], Detail: matches[3]}
+	_, _ = fmt.Sscanf(matches[2], "%d", &update.Percent)
+
+	// Keep transfer size and throughput next to the object counts
+	if matches[4] != "" && matches[4] != "done." {
+		update.Detail += ", " + matches[4]
+	}
+
+	return update, true
+}
+
+// scanProgressLines splits git output on both newlines and the carriage
+// returns git uses to redraw its progress lines
+func scanProgressLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// runGitWithProgress runs a git command that was given --progress. Its phases are
+// rendered through a ui.Progress bar, or passed through untouched when verbose is set.
+// The raw output is returned either way so it can be inspected or saved. A nil env
+// inherits gclone's environment.
+func runGitWithProgress(verbose bool, env []string, args ...string) ([]byte, error) {
+	var raw, stdout bytes.Buffer
+
+	cmd := exec.Command("git", args...)
+	cmd.Env = env
+	cmd.Stdout = &stdout
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader = io.TeeReader(stderr, &raw)
+	if verbose {
+		reader = io.TeeReader(reader, os.Stderr)
+	}
+
+	progress := ui.NewProgress()
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(scanProgressLines)
+	for scanner.Scan() {
+		if verbose {
+			continue
+		}
+		if update, ok := ParseProgressLine(scanner.Text()); ok {
+			progress.Update(update.Phase, update.Percent, update.Detail)
+		}
+	}
+	progress.Done()
+
+	err = cmd.Wait()
+	raw.Write(stdout.Bytes())
+	return raw.Bytes(), err
+}
+
+// lastErrorLine returns the most relevant error message from raw git output
+func lastErrorLine(output []byte) string {
+	lines := strings.FieldsFunc(string(output), func(r rune) bool {
+		return r == '\n' || r == '\r'
+	})
+
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, "fatal:") || strings.HasPrefix(line, "error:") {
+			return line
+		}
+	}
+	return ""
+}
+
+// saveGitLog keeps the raw output of a failed git command under ~/.gclone/logs
+func saveGitLog(name string, output []byte) (string, error) {
+	logDir := filepath.Join(config.DefaultConfigDir(), "logs")
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return "", err
+	}
+
+	logFile := filepath.Join(logDir, fmt.Sprintf("%s-%s.log", name, time.Now().Format("20060102-150405")))
+	if err := os.WriteFile(logFile, output, 0644); err != nil {
+		return "", err
+	}
+
+	return logFile, nil
+}
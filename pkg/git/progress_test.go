@@ -0,0 +1,89 @@
+package git
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProgressLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want ProgressUpdate
+		ok   bool
+	}{
+		{
+			name: "remote phase",
+			line: "remote: Counting objects:  45% (9/20)",
+			want: ProgressUpdate{Phase: "Counting objects", Percent: 45, Detail: "9/20"},
+			ok:   true,
+		},
+		{
+			name: "carriage return redraw",
+			line: "remote: Compressing objects:   7% (1/14)\r",
+			want: ProgressUpdate{Phase: "Compressing objects", Percent: 7, Detail: "1/14"},
+			ok:   true,
+		},
+		{
+			name: "transfer size and throughput",
+			line: "Receiving objects:  62% (124/200), 1.20 MiB | 2.00 MiB/s\r",
+			want: ProgressUpdate{Phase: "Receiving objects", Percent: 62, Detail: "124/200, 1.20 MiB | 2.00 MiB/s"},
+			ok:   true,
+		},
+		{
+			name: "done",
+			line: "Resolving deltas: 100% (5/5), done.\n",
+			want: ProgressUpdate{Phase: "Resolving deltas", Percent: 100, Detail: "5/5"},
+			ok:   true,
+		},
+		{
+			name: "partial percentage",
+			line: "Receiving objects:  4",
+		},
+		{
+			name: "percentage without counts",
+			line: "Receiving objects:  45%",
+		},
+		{
+			name: "message",
+			line: "Cloning into 'repo'...",
+		},
+		{
+			name: "empty",
+			line: "\r",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseProgressLine(tt.line)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("ParseProgressLine(%q) = %+v, %v, want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestScanProgressLines(t *testing.T) {
+	output := "Cloning into 'repo'...\nReceiving objects:  50% (1/2)\rReceiving objects: 100% (2/2), done.\r\nResolving deltas:  0% (0/1)"
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Split(scanProgressLines)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	want := []string{
+		"Cloning into 'repo'...",
+		"Receiving objects:  50% (1/2)",
+		"Receiving objects: 100% (2/2), done.",
+		"",
+		"Resolving deltas:  0% (0/1)",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("scanProgressLines split %q into %q, want %q", output, lines, want)
+	}
+}
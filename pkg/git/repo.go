@@ -0,0 +1,150 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// Remote is a named remote of a repository
+type Remote struct {
+	Name string
+	URL  string
+}
+
+// FindRepositoryRoot returns the top-level directory of the repository containing path
+func FindRepositoryRoot(path string) (string, error) {
+	root, err := gitOutput(path, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fmt.Errorf("%s is not inside a git repository: %w", path, err)
+	}
+	return root, nil
+}
+
+// ListRemotes returns the remotes of a repository, sorted by name
+func ListRemotes(repoPath string) ([]Remote, error) {
+	out, err := gitOutput(repoPath, "config", "--local", "--get-regexp", `^remote\..*\.url$`)
+	if err != nil {
+		// git config exits with 1 when nothing matches
+		var gitErr *GitError
+		if errors.As(err, &gitErr) && gitErr.ExitCode == 1 {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var remotes []Remote
+	for _, line := range strings.Split(out, "\n") {
+		key, url, found := strings.Cut(line, " ")
+		if !found {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "remote."), ".url")
+		remotes = append(remotes, Remote{Name: name, URL: url})
+	}
+
+	sort.Slice(remotes, func(i, j int) bool {
+		return remotes[i].Name < remotes[j].Name
+	})
+
+	return remotes, nil
+}
+
+// GetRemoteURL returns the URL of a named remote
+func GetRemoteURL(repoPath, name string) (string, error) {
+	return gitOutput(repoPath, "remote", "get-url", name)
+}
+
+// SetRemoteURL changes the URL of a named remote
+func SetRemoteURL(repoPath, name, url string) error {
+	_, err := gitOutput(repoPath, "remote", "set-url", name, url)
+	return err
+}
+
+// GetLocalConfig reads a value from the repository's local git config.
+// The boolean reports whether the key is set.
+func GetLocalConfig(repoPath, key string) (string, bool, error) {
+	value, err := gitOutput(repoPath, "config", "--local", "--get", key)
+	if err != nil {
+		// git config exits with 1 when the key is not set
+		var gitErr *GitError
+		if errors.As(err, &gitErr) && gitErr.ExitCode == 1 {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// gitOutput runs a git command in dir and returns its trimmed standard output
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", newGitError(args, nil, err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// Bookkeeping kept in a repository's local git config, so gclone knows which
+// profile it applied and which keys it is responsible for
+const (
+	profileRecordKey    = "gclone.profile"
+	managedKeyRecordKey = "gclone.managedkey"
+)
+
+// ReadProfileRecord returns the profile gclone last applied to a repository and
+// the git config keys it set. Both are empty for repositories gclone never touched.
+func ReadProfileRecord(repoPath string) (string, []string, error) {
+	profileName, _, err := GetLocalConfig(repoPath, profileRecordKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	out, err := gitOutput(repoPath, "config", "--local", "--get-all", managedKeyRecordKey)
+	if err != nil {
+		var gitErr *GitError
+		if errors.As(err, &gitErr) && gitErr.ExitCode == 1 {
+			return profileName, nil, nil
+		}
+		return "", nil, err
+	}
+
+	keys := strings.Split(out, "\n")
+	sort.Strings(keys)
+	return profileName, keys, nil
+}
+
+// WriteProfileRecord remembers the profile applied to a repository and the git config keys it set
+func WriteProfileRecord(repoPath, profileName string, keys []string) error {
+	if _, err := gitOutput(repoPath, "config", "--local", profileRecordKey, profileName); err != nil {
+		return fmt.Errorf("failed to record profile: %w", err)
+	}
+
+	if err := UnsetLocalConfig(repoPath, managedKeyRecordKey); err != nil {
+		return fmt.Errorf("failed to record managed keys: %w", err)
+	}
+	for _, key := range keys {
+		if _, err := gitOutput(repoPath, "config", "--local", "--add", managedKeyRecordKey, key); err != nil {
+			return fmt.Errorf("failed to record managed keys: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// UnsetLocalConfig removes every value of a key from the repository's local git config
+func UnsetLocalConfig(repoPath, key string) error {
+	if _, err := gitOutput(repoPath, "config", "--local", "--unset-all", key); err != nil {
+		// git config exits with 5 when the key is not set
+		var gitErr *GitError
+		if errors.As(err, &gitErr) && gitErr.ExitCode == 5 {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/user-cube/gclone/pkg/config"
+)
+
+// CloneShared clones a subscribed repository of shared profiles into its directory
+func CloneShared(s *config.Subscription) error {
+	if err := os.MkdirAll(config.SharedDir(), 0755); err != nil {
+		return fmt.Errorf("error creating %s: %w", config.SharedDir(), err)
+	}
+	return runGit("", nil, "clone", "--quiet", s.URL, s.Dir())
+}
+
+// UpdateShared brings a subscribed repository up to date with its remote.
+// The fetched profile files are checked first (see config.Subscription.Check)
+// and the copy stays at its current commit when they have errors. The copy is
+// read-only, so anything changed locally is discarded.
+func UpdateShared(s *config.Subscription) error {
+	dir := s.Dir()
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := CloneShared(s); err != nil {
+			return err
+		}
+		if err := s.Check(""); err != nil {
+			_ = os.RemoveAll(dir)
+			return err
+		}
+		return nil
+	}
+	if err := runGit(dir, nil, "fetch", "--quiet", "--prune", "origin"); err != nil {
+		return err
+	}
+	if err := checkSharedRevision(s, "@{upstream}"); err != nil {
+		return err
+	}
+	return runGit(dir, nil, "reset", "--quiet", "--hard", "@{upstream}")
+}
+
+// checkSharedRevision checks the profile files of a subscription at a revision
+// of its copy, in a temporary worktree so the copy itself is left untouched
+func checkSharedRevision(s *config.Subscription, revision string) error {
+	tmp, err := os.MkdirTemp("", "gclone-shared-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	worktree := filepath.Join(tmp, s.Name)
+	if err := runGit(s.Dir(), nil, "worktree", "add", "--quiet", "--detach", worktree, revision); err != nil {
+		return err
+	}
+	defer func() {
+		_ = runGit(s.Dir(), nil, "worktree", "remove", "--force", worktree)
+	}()
+	return s.Check(worktree)
+}
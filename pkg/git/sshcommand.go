@@ -0,0 +1,64 @@
+package git
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/user-cube/gclone/pkg/config"
+)
+
+// sshCommandKey is the git config key holding the SSH command of a repository
+const sshCommandKey = "core.sshCommand"
+
+// shellSafeRegex matches words that need no quoting in a shell command
+var shellSafeRegex = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./~-]+$`)
+
+// SSHCommand returns the ssh invocation for a profile with an identity file,
+// or an empty string when the profile relies on an SSH host alias instead
+func SSHCommand(profile *config.Profile) string {
+	if profile == nil || profile.IdentityFile == "" {
+		return ""
+	}
+
+	words := []string{"ssh", "-i", ShellQuote(profile.IdentityFile), "-o", "IdentitiesOnly=yes"}
+	for _, option := range profile.SSHOptions {
+		words = append(words, "-o", ShellQuote(option))
+	}
+	return strings.Join(words, " ")
+}
+
+// ProfileGitConfigs returns every git config a profile sets in a repository:
+// its git_configs plus core.sshCommand when it uses an identity file
+func ProfileGitConfigs(profile *config.Profile) map[string]string {
+	sshCommand := SSHCommand(profile)
+	if sshCommand == "" {
+		return profile.GitConfigs
+	}
+
+	configs := make(map[string]string, len(profile.GitConfigs)+1)
+	for key, value := range profile.GitConfigs {
+		configs[key] = value
+	}
+	configs[sshCommandKey] = sshCommand
+	return configs
+}
+
+// sshEnv returns the environment for git commands that talk to the remote of a
+// profile, or nil to inherit gclone's own environment
+func sshEnv(profile *config.Profile) []string {
+	sshCommand := SSHCommand(profile)
+	if sshCommand == "" {
+		return nil
+	}
+	return append(os.Environ(), "GIT_SSH_COMMAND="+sshCommand)
+}
+
+// ShellQuote quotes a word for POSIX shells, such as the one git runs
+// core.sshCommand with. Words made only of safe characters are left as they are.
+func ShellQuote(word string) string {
+	if shellSafeRegex.MatchString(word) {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
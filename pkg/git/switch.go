@@ -0,0 +1,108 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/sshconfig"
+)
+
+// PlanSwitch works out the changes that move a repository from one profile to
+// another. Remotes going through an SSH host alias are mapped back to the real
+// host with the SSH config before being pointed at the new profile; the plan is
+// refused when that is not possible. from may be nil when the repository did not
+// follow any profile.
+func PlanSwitch(repoPath string, from *config.Profile, toName string, to *config.Profile, profiles map[string]config.Profile, hosts *sshconfig.Config) (*ApplyPlan, error) {
+	plan, err := PlanApply(repoPath, toName, to)
+	if err != nil {
+		return nil, err
+	}
+
+	remotes, err := ListRemotes(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading remotes: %w", err)
+	}
+
+	plan.Remotes = nil
+	for _, remote := range remotes {
+		host, path, ok := ParseSSHURL(remote.URL)
+		if !ok {
+			// Already reported as skipped by PlanApply
+			continue
+		}
+
+		realHost, err := realHostname(host, profiles, hosts)
+		if err != nil {
+			return nil, fmt.Errorf("cannot switch remote %s: %w", remote.Name, err)
+		}
+
+		newHost := realHost
+		if to.SSHHost != "" && to.IdentityFile == "" {
+			// The new alias must lead to the same server, or the remote would point elsewhere
+			if aliasHost, found := hosts.Hostname(to.SSHHost); found && aliasHost != realHost {
+				return nil, fmt.Errorf("cannot switch remote %s: the repository is on %s but SSH host %s connects to %s",
+					remote.Name, realHost, to.SSHHost, aliasHost)
+			}
+			newHost = to.SSHHost
+		}
+
+		newURL := fmt.Sprintf("git@%s:%s", newHost, path)
+		if newURL != remote.URL {
+			plan.Remotes = append(plan.Remotes, RemoteChange{Name: remote.Name, OldURL: remote.URL, NewURL: newURL})
+		}
+	}
+
+	// Repositories set up before gclone kept a record still carry the old profile's keys
+	if from != nil {
+		toConfigs := ProfileGitConfigs(to)
+		fromConfigs := ProfileGitConfigs(from)
+		keys := make([]string, 0, len(fromConfigs))
+		for key := range fromConfigs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if _, kept := toConfigs[key]; kept || plan.removes(key) {
+				continue
+			}
+			current, isSet, err := GetLocalConfig(repoPath, key)
+			if err != nil {
+				return nil, fmt.Errorf("error reading git config %s: %w", key, err)
+			}
+			if isSet {
+				plan.Removals = append(plan.Removals, ConfigChange{Key: key, OldValue: current, WasSet: true})
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// removes reports whether the plan already unsets a key
+func (p *ApplyPlan) removes(key string) bool {
+	for _, change := range p.Removals {
+		if change.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// realHostname maps a host from a remote URL to the server it connects to. Hosts
+// used as a profile's SSH host are aliases and must be defined in the SSH config.
+func realHostname(host string, profiles map[string]config.Profile, hosts *sshconfig.Config) (string, error) {
+	if hostname, found := hosts.Hostname(host); found {
+		return hostname, nil
+	}
+
+	for name, profile := range profiles {
+		if profile.SSHHost == host {
+			return "", fmt.Errorf("%s is the SSH host alias of profile '%s' but has no Hostname in ~/.ssh/config or ~/.gclone/ssh_config, so the real host is unknown", host, name)
+		}
+	}
+
+	// Not an alias gclone knows about, so it is the real host
+	return host, nil
+}
@@ -0,0 +1,151 @@
+// Package registry keeps track of the repositories managed by gclone.
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/user-cube/gclone/pkg/config"
+	"github.com/user-cube/gclone/pkg/fileutil"
+)
+
+// SchemaVersion is the version of the registry file format written by this build
+const SchemaVersion = 1
+
+// Entry records a repository managed by gclone
+type Entry struct {
+	Path          string    `json:"path"`
+	URL           string    `json:"url"`
+	Profile       string    `json:"profile"`
+	ClonedAt      time.Time `json:"cloned_at"`
+	GcloneVersion string    `json:"gclone_version"`
+}
+
+// Registry is the list of repositories managed by gclone
+type Registry struct {
+	Version      int     `json:"version"`
+	Repositories []Entry `json:"repositories"`
+}
+
+// DefaultRegistryFile returns the default registry file path
+func DefaultRegistryFile() string {
+	return filepath.Join(config.DefaultConfigDir(), "repos.json")
+}
+
+// Load reads the registry from the specified file. A missing file is an empty registry.
+func Load(registryFile string) (*Registry, error) {
+	if registryFile == "" {
+		registryFile = DefaultRegistryFile()
+	}
+
+	data, err := os.ReadFile(registryFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Registry{Version: SchemaVersion, Repositories: []Entry{}}, nil
+		}
+		return nil, fmt.Errorf("error reading registry: %w", err)
+	}
+
+	var registry Registry
+	if err := json.Unmarshal(data, &registry); err != nil {
+		return nil, fmt.Errorf("error parsing registry %s: %w", registryFile, err)
+	}
+
+	if registry.Version > SchemaVersion {
+		return nil, fmt.Errorf("registry %s was written by a newer gclone (schema version %d, this build supports %d)",
+			registryFile, registry.Version, SchemaVersion)
+	}
+	registry.Version = SchemaVersion
+	if registry.Repositories == nil {
+		registry.Repositories = []Entry{}
+	}
+
+	return &registry, nil
+}
+
+// Update loads the registry, applies fn and saves the result while holding a
+// lock, so concurrent gclone processes never lose each other's changes
+func Update(registryFile string, fn func(*Registry) error) error {
+	if registryFile == "" {
+		registryFile = DefaultRegistryFile()
+	}
+
+	lock, err := fileutil.LockFile(registryFile)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = lock.Unlock()
+	}()
+
+	registry, err := Load(registryFile)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(registry); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding registry: %w", err)
+	}
+
+	if err := fileutil.WriteFileAtomic(registryFile, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing registry: %w", err)
+	}
+
+	return nil
+}
+
+// Add records a repository, replacing any previous entry for the same path
+func (r *Registry) Add(entry Entry) {
+	for i := range r.Repositories {
+		if r.Repositories[i].Path == entry.Path {
+			r.Repositories[i] = entry
+			return
+		}
+	}
+
+	r.Repositories = append(r.Repositories, entry)
+	sort.Slice(r.Repositories, func(i, j int) bool {
+		return r.Repositories[i].Path < r.Repositories[j].Path
+	})
+}
+
+// Find returns the entry for a repository path
+func (r *Registry) Find(path string) (*Entry, bool) {
+	for i := range r.Repositories {
+		if r.Repositories[i].Path == path {
+			return &r.Repositories[i], true
+		}
+	}
+	return nil, false
+}
+
+// Remove forgets the entry for a repository path, reporting whether it existed
+func (r *Registry) Remove(path string) bool {
+	for i := range r.Repositories {
+		if r.Repositories[i].Path == path {
+			r.Repositories = append(r.Repositories[:i], r.Repositories[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ForProfile returns the entries of repositories cloned with a profile
+func (r *Registry) ForProfile(profileName string) []Entry {
+	var entries []Entry
+	for _, entry := range r.Repositories {
+		if entry.Profile == profileName {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,193 @@
+// Package sshconfig reads the host aliases defined in OpenSSH client config files.
+package sshconfig
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxIncludeDepth stops Include loops, matching the limit used by OpenSSH
+const maxIncludeDepth = 16
+
+// Config maps SSH host aliases to the real host names they connect to
+type Config struct {
+	hostnames map[string]string
+	// patterns are the Host patterns of every block read, negations excluded
+	patterns []string
+}
+
+// DefaultFiles returns the SSH config files gclone reads: the user's own config
+// and the one generated by 'gclone ssh-config'
+func DefaultFiles() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []string{
+		filepath.Join(home, ".ssh", "config"),
+		filepath.Join(home, ".gclone", "ssh_config"),
+	}
+}
+
+// Load parses SSH config files, following Include directives. Missing files are skipped.
+func Load(files ...string) (*Config, error) {
+	c := &Config{hostnames: make(map[string]string)}
+	for _, file := range files {
+		if err := c.parseFile(file, 0); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Hostname returns the real host an alias connects to. Only aliases with an
+// explicit Hostname are known.
+func (c *Config) Hostname(alias string) (string, bool) {
+	hostname, ok := c.hostnames[alias]
+	return hostname, ok
+}
+
+// HasHost reports whether a Host block applies to an alias, matching wildcard
+// patterns as ssh does
+func (c *Config) HasHost(alias string) bool {
+	for _, pattern := range c.patterns {
+		if pattern == "*" {
+			// The catch-all block holds defaults, not a host of its own
+			continue
+		}
+		if matched, _ := filepath.Match(pattern, alias); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFile reads the Host and Hostname directives of one file
+func (c *Config) parseFile(file string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("too many nested Include directives in %s", file)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading %s: %w", file, err)
+	}
+	defer f.Close()
+
+	// Aliases of the Host block being read; nil inside Match blocks
+	var aliases []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		keyword, args := splitLine(scanner.Text())
+		switch strings.ToLower(keyword) {
+		case "host":
+			aliases = aliases[:0]
+			for _, pattern := range args {
+				if !strings.HasPrefix(pattern, "!") {
+					c.patterns = append(c.patterns, pattern)
+				}
+				// Wildcards and negations cannot be mapped back to a single alias
+				if !strings.ContainsAny(pattern, "*?!") {
+					aliases = append(aliases, pattern)
+				}
+			}
+		case "match":
+			aliases = nil
+		case "hostname":
+			if len(args) == 0 {
+				continue
+			}
+			for _, alias := range aliases {
+				// As in ssh, the first value obtained for a host wins
+				if _, seen := c.hostnames[alias]; !seen {
+					c.hostnames[alias] = expandTokens(args[0], alias)
+				}
+			}
+		case "include":
+			for _, pattern := range args {
+				matches, err := filepath.Glob(includePath(pattern))
+				if err != nil {
+					return fmt.Errorf("invalid Include %s in %s: %w", pattern, file, err)
+				}
+				for _, match := range matches {
+					if err := c.parseFile(match, depth+1); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading %s: %w", file, err)
+	}
+
+	return nil
+}
+
+// splitLine splits a config line into its keyword and arguments. Keywords may be
+// separated from their arguments by whitespace or '=', and arguments may be quoted.
+func splitLine(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil
+	}
+
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return line, nil
+	}
+	keyword := line[:end]
+	rest := strings.TrimLeft(line[end:], " \t")
+	rest = strings.TrimLeft(strings.TrimPrefix(rest, "="), " \t")
+
+	var args []string
+	for rest != "" {
+		if rest[0] == '"' {
+			closing := strings.IndexByte(rest[1:], '"')
+			if closing < 0 {
+				args = append(args, rest[1:])
+				break
+			}
+			args = append(args, rest[1:closing+1])
+			rest = strings.TrimLeft(rest[closing+2:], " \t")
+			continue
+		}
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			args = append(args, rest)
+			break
+		}
+		args = append(args, rest[:end])
+		rest = strings.TrimLeft(rest[end:], " \t")
+	}
+	return keyword, args
+}
+
+// includePath resolves an Include argument; relative paths are relative to ~/.ssh
+func includePath(pattern string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return pattern
+	}
+	if pattern == "~" || strings.HasPrefix(pattern, "~/") {
+		return filepath.Join(home, strings.TrimPrefix(pattern, "~"))
+	}
+	if !filepath.IsAbs(pattern) {
+		return filepath.Join(home, ".ssh", pattern)
+	}
+	return pattern
+}
+
+// expandTokens expands the %h and %% tokens allowed in Hostname
+func expandTokens(hostname, alias string) string {
+	hostname = strings.ReplaceAll(hostname, "%%", "\x00")
+	hostname = strings.ReplaceAll(hostname, "%h", alias)
+	return strings.ReplaceAll(hostname, "\x00", "%")
+}
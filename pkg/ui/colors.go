@@ -2,24 +2,50 @@
 package ui
 
 import (
+	"io"
+	"os"
+
 	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
 )
 
+// Output receives all human-facing messages: progress, warnings, prompts,
+// tables and dry-run previews. It is stderr so that stdout stays reserved for
+// data a command is asked for, such as JSON, 'config get' values or 'env'
+// exports, which commands write to cmd.OutOrStdout().
+var Output io.Writer = color.Error
+
 var (
 	// Success prints text in green color
-	Success = color.New(color.FgGreen).PrintfFunc()
+	Success = printfFunc(color.New(color.FgGreen))
 	// Info prints text in cyan color
-	Info = color.New(color.FgCyan).PrintfFunc()
+	Info = printfFunc(color.New(color.FgCyan))
 	// Warning prints text in yellow color
-	Warning = color.New(color.FgYellow).PrintfFunc()
+	Warning = printfFunc(color.New(color.FgYellow))
 	// Error prints text in red color
-	Error = color.New(color.FgRed).PrintfFunc()
+	Error = printfFunc(color.New(color.FgRed))
 	// Highlight prints text in yellow color, useful for emphasizing values
 	Highlight = color.New(color.FgYellow).SprintFunc()
 	// Normal prints text in white color
-	Normal = color.New(color.FgWhite).PrintfFunc()
+	Normal = printfFunc(color.New(color.FgWhite))
 )
 
+func init() {
+	// color only checks stdout, but our messages go to stderr
+	if !isatty.IsTerminal(os.Stderr.Fd()) && !isatty.IsCygwinTerminal(os.Stderr.Fd()) {
+		color.NoColor = true
+	} else if os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" {
+		color.NoColor = false
+	}
+}
+
+// printfFunc returns a printf style function writing colored text to Output
+func printfFunc(c *color.Color) func(format string, a ...interface{}) {
+	return func(format string, a ...interface{}) {
+		_, _ = c.Fprintf(Output, format, a...)
+	}
+}
+
 // Colors creates and returns commonly used colored print functions
 type Colors struct {
 	Red    func(a ...interface{}) string
@@ -45,7 +71,7 @@ func NewColors() *Colors {
 // Section prints a section header with a newline before and after
 func Section(title string) {
 	Normal("\n")
-	_, _ = color.New(color.FgCyan, color.Bold).Printf("=== %s ===\n", title)
+	_, _ = color.New(color.FgCyan, color.Bold).Fprintf(Output, "=== %s ===\n", title)
 	Normal("\n")
 }
 
@@ -7,5 +7,5 @@ import (
 // PrintInfo prints a formatted information label and value
 func PrintInfo(label string, value string) {
 	colors := NewColors()
-	fmt.Printf("%s: %s\n", colors.Bold(label), value)
+	_, _ = fmt.Fprintf(Output, "%s: %s\n", colors.Bold(label), value)
 }
@@ -23,3 +23,11 @@ func OperationSuccess(message string, details ...string) {
 func OperationError(operation string, err error) {
 	Error("Error %s: %v\n", operation, err)
 }
+
+// PrintChange displays a before/after pair as a small diff
+func PrintChange(label, before, after string) {
+	colors := NewColors()
+	Normal("  %s\n", label)
+	Normal("    %s %s\n", colors.Red("-"), before)
+	Normal("    %s %s\n", colors.Green("+"), after)
+}
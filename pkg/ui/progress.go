@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Progress renders the phases of a long running operation as a progress bar
+type Progress struct {
+	out      io.Writer
+	width    int
+	terminal bool
+	phase    string
+	percent  int
+	active   bool
+}
+
+// NewProgress creates a progress bar writing to the standard UI output
+func NewProgress() *Progress {
+	return &Progress{
+		out:      Output,
+		width:    30,
+		terminal: isatty.IsTerminal(os.Stderr.Fd()) || isatty.IsCygwinTerminal(os.Stderr.Fd()),
+	}
+}
+
+// Update shows the current state of a phase; switching phases completes the previous one
+func (p *Progress) Update(phase string, percent int, detail string) {
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+
+	if p.active && phase != p.phase {
+		p.finish()
+	}
+
+	// Ignore repeated reports for a phase that was already completed
+	if !p.active && phase == p.phase && p.percent == 100 {
+		return
+	}
+
+	p.phase = phase
+	p.percent = percent
+	p.active = true
+
+	if !p.terminal {
+		// Without a terminal the bar cannot be redrawn, so only report completed phases
+		if percent == 100 {
+			p.finish()
+		}
+		return
+	}
+
+	filled := p.width * percent / 100
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", p.width-filled)
+	if filled > 0 && filled < p.width {
+		bar = strings.Repeat("=", filled-1) + ">" + strings.Repeat(" ", p.width-filled)
+	}
+
+	colors := NewColors()
+	line := fmt.Sprintf("  %-20s [%s] %3d%%", phase, colors.Cyan(bar), percent)
+	if detail != "" {
+		line += "  " + colors.Faint(detail)
+	}
+	_, _ = fmt.Fprintf(p.out, "\r\033[K%s", line)
+}
+
+// Done completes the current phase, if any
+func (p *Progress) Done() {
+	if p.active {
+		p.finish()
+	}
+}
+
+// finish ends the line of the current phase
+func (p *Progress) finish() {
+	if p.terminal {
+		_, _ = fmt.Fprint(p.out, "\n")
+	} else {
+		_, _ = fmt.Fprintf(p.out, "  %s: %d%%\n", p.phase, p.percent)
+	}
+	p.active = false
+}
@@ -1,14 +1,60 @@
 package ui
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/manifoldco/promptui"
 )
 
-// SelectFromList prompts the user to select an item from a list
-func SelectFromList(label string, items []string) (string, error) {
+// promptOutput draws prompts on stderr, next to the other human-facing messages
+var promptOutput = nopWriteCloser{os.Stderr}
+
+// nopWriteCloser satisfies the io.WriteCloser promptui expects without closing stderr
+type nopWriteCloser struct {
+	*os.File
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// interactive reports whether prompts are allowed to wait for user input
+var interactive = true
+
+// SetInteractive enables or disables interactive prompts
+func SetInteractive(enabled bool) {
+	interactive = enabled
+}
+
+// IsInteractive reports whether prompts are allowed to wait for user input
+func IsInteractive() bool {
+	return interactive
+}
+
+// NonInteractiveError is returned by prompts when gclone runs non-interactively
+type NonInteractiveError struct {
+	// Prompt is the label of the prompt that could not be shown
+	Prompt string
+	// Flag names the flag or argument that supplies the answer instead
+	Flag string
+}
+
+func (e *NonInteractiveError) Error() string {
+	return fmt.Sprintf("cannot prompt for %q in non-interactive mode, provide %s instead", e.Prompt, e.Flag)
+}
+
+// SelectFromList prompts the user to select an item from a list.
+// flag names the option that supplies the answer in non-interactive mode.
+func SelectFromList(label string, items []string, flag string) (string, error) {
+	if !interactive {
+		return "", &NonInteractiveError{Prompt: label, Flag: flag}
+	}
+
 	prompt := promptui.Select{
-		Label: label,
-		Items: items,
+		Label:  label,
+		Items:  items,
+		Stdout: promptOutput,
 	}
 
 	idx, _, err := prompt.Run()
@@ -19,11 +65,17 @@ func SelectFromList(label string, items []string) (string, error) {
 	return items[idx], nil
 }
 
-// Confirm prompts the user for a yes/no confirmation
-func Confirm(label string) (bool, error) {
+// Confirm prompts the user for a yes/no confirmation.
+// flag names the option that supplies the answer in non-interactive mode.
+func Confirm(label string, flag string) (bool, error) {
+	if !interactive {
+		return false, &NonInteractiveError{Prompt: label, Flag: flag}
+	}
+
 	prompt := promptui.Prompt{
 		Label:     label,
 		IsConfirm: true,
+		Stdout:    promptOutput,
 	}
 
 	_, err := prompt.Run()
@@ -38,12 +90,18 @@ func Confirm(label string) (bool, error) {
 	return true, nil
 }
 
-// PromptInput prompts the user for text input
-func PromptInput(label string, defaultValue string, validate promptui.ValidateFunc) (string, error) {
+// PromptInput prompts the user for text input.
+// flag names the option that supplies the answer in non-interactive mode.
+func PromptInput(label string, defaultValue string, validate promptui.ValidateFunc, flag string) (string, error) {
+	if !interactive {
+		return "", &NonInteractiveError{Prompt: label, Flag: flag}
+	}
+
 	prompt := promptui.Prompt{
 		Label:    label,
 		Default:  defaultValue,
 		Validate: validate,
+		Stdout:   promptOutput,
 	}
 
 	return prompt.Run()
@@ -41,7 +41,7 @@ func (s *Spinner) Start() {
 			default:
 				// Use the Info function to color the spinner
 				frame := s.frames[i]
-				fmt.Printf("\r%s %s", frame, s.message)
+				_, _ = fmt.Fprintf(Output, "\r%s %s", frame, s.message)
 				i = (i + 1) % len(s.frames)
 				time.Sleep(s.frameRate)
 			}
@@ -56,9 +56,9 @@ func (s *Spinner) Stop() {
 	}
 	s.active = false
 	s.done <- true
-	fmt.Print("\r")
+	_, _ = fmt.Fprint(Output, "\r")
 	// Clear the line
-	fmt.Print("\033[K")
+	_, _ = fmt.Fprint(Output, "\033[K")
 }
 
 // WithSpinner runs a function with a spinner
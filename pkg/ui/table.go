@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"fmt"
 	"strings"
 )
 
@@ -40,7 +41,8 @@ func (t *Table) AddRow(values ...string) {
 	t.Rows = append(t.Rows, values)
 }
 
-// Print prints the table
+// Print writes the table to Output. Tables are for reading, like the other
+// messages; commands whose results feed scripts offer --output json instead.
 func (t *Table) Print() {
 	if len(t.Columns) == 0 {
 		return
@@ -55,7 +57,7 @@ func (t *Table) Print() {
 			}
 			headerString += padString(col.Header, col.Width)
 		}
-		Normal("%s\n", headerString)
+		_, _ = fmt.Fprintln(Output, headerString)
 
 		// Print separator
 		separator := ""
@@ -65,7 +67,7 @@ func (t *Table) Print() {
 			}
 			separator += strings.Repeat("-", col.Width)
 		}
-		Normal("%s\n", separator)
+		_, _ = fmt.Fprintln(Output, separator)
 	}
 
 	// Print rows
@@ -77,7 +79,7 @@ func (t *Table) Print() {
 			}
 			rowString += padString(val, t.Columns[i].Width)
 		}
-		Normal("%s\n", rowString)
+		_, _ = fmt.Fprintln(Output, rowString)
 	}
 }
 